@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"goPalettes/imageManip"
+	"os"
+)
+
+// base16 [flags] image
+// Prints a Base16 (or Base24) scheme YAML built from the image's palette.
+func runBase16(args []string) error {
+	fset := flag.NewFlagSet("base16", flag.ExitOnError)
+	base24 := fset.Bool("base24", false, "write a Base24 scheme")
+	variant := fset.String("variant", imageManip.BASE16_DARK, "dark or light")
+	name := fset.String("name", "", "scheme name (default: image file name)")
+	author := fset.String("author", "goPalettes", "scheme author")
+	ef := addExtractFlags(fset, 12)
+	fset.Parse(args)
+
+	if fset.NArg() != 1 {
+		return errors.New("Usage: goPalettes base16 [flags] image")
+	}
+	path := fset.Arg(0)
+	if *name == "" {
+		*name = schemeName(path)
+	}
+
+	palette, err := ef.extract(path)
+	if err != nil {
+		return err
+	}
+	scheme, err := imageManip.GenerateBase16(*name, *author, palette, *variant, *base24)
+	if err != nil {
+		return err
+	}
+	return imageManip.WriteBase16(os.Stdout, scheme)
+}
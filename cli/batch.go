@@ -0,0 +1,446 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"goPalettes/imageManip"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// One line of the batch manifest.
+type manifestRow struct {
+	Path      string                  `json:"path"`
+	Width     int                     `json:"width"`
+	Height    int                     `json:"height"`
+	Algorithm string                  `json:"algorithm"`
+	Palette   []imageManip.ColAndFreq `json:"palette"`
+	Names     []imageManip.ColorName  `json:"names,omitempty"`
+	BlurHash  string                  `json:"blurHash,omitempty"`
+	ThumbHash string                  `json:"thumbHash,omitempty"`
+	Millis    float64                 `json:"millis"`
+	Cached    bool                    `json:"cached,omitempty"`
+	Error     string                  `json:"error,omitempty"`
+}
+
+var csvHeader = []string{"path", "width", "height", "algorithm", "palette", "millis", "error", "names", "blurhash", "thumbhash"}
+
+// Writes rows as JSON lines or CSV.
+type manifestWriter interface {
+	write(row manifestRow) error
+}
+
+type jsonManifest struct {
+	enc *json.Encoder
+}
+
+func (m jsonManifest) write(row manifestRow) error {
+	return m.enc.Encode(row)
+}
+
+type csvManifest struct {
+	w *csv.Writer
+}
+
+func (m csvManifest) write(row manifestRow) error {
+	m.w.Write([]string{
+		row.Path,
+		strconv.Itoa(row.Width),
+		strconv.Itoa(row.Height),
+		row.Algorithm,
+		paletteToCSV(row.Palette),
+		strconv.FormatFloat(row.Millis, 'f', 1, 64),
+		row.Error,
+		namesToCSV(row.Names),
+		row.BlurHash,
+		row.ThumbHash,
+	})
+	// Flush every row so an interrupted run keeps what it finished.
+	m.w.Flush()
+	return m.w.Error()
+}
+
+// "#aabbcc:120 #ddeeff:80"
+func paletteToCSV(palette []imageManip.ColAndFreq) string {
+	parts := make([]string, len(palette))
+	for i, c := range palette {
+		parts[i] = c.ColString + ":" + strconv.Itoa(c.Frequency)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Inverse of paletteToCSV.
+func paletteFromCSV(field string) ([]imageManip.ColAndFreq, error) {
+	var palette []imageManip.ColAndFreq
+	for _, part := range strings.Fields(field) {
+		i := strings.LastIndex(part, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("Bad palette entry %q.", part)
+		}
+		freq, err := strconv.Atoi(part[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("Bad palette entry %q.", part)
+		}
+		if _, err := parseHexList(part[:i]); err != nil {
+			return nil, err
+		}
+		palette = append(palette, imageManip.ColAndFreq{ColString: part[:i], Frequency: freq})
+	}
+	return palette, nil
+}
+
+// "navy;teal-ish", in palette order.
+func namesToCSV(names []imageManip.ColorName) string {
+	labels := make([]string, len(names))
+	for i, n := range names {
+		labels[i] = n.Label()
+	}
+	return strings.Join(labels, ";")
+}
+
+// batch [flags] [dir|file ...]
+// Walks the given directories (or reads paths from stdin when there are
+// none, or the only one is "-") and writes one manifest row per image.
+func runBatch(args []string) error {
+	fset := flag.NewFlagSet("batch", flag.ExitOnError)
+	out := fset.String("o", "manifest.jsonl", "manifest file")
+	format := fset.String("format", "", "manifest format, json or csv (default from the -o extension)")
+	workers := fset.Int("workers", runtime.NumCPU(), "images processed at the same time")
+	algorithm := fset.String("algorithm", imageManip.DEFAULT_EXTRACTOR, fmt.Sprintf("one of %v", imageManip.ExtractorNames()))
+	count := fset.Int("count", 5, "colors per palette")
+	tolerance := fset.Float64("tolerance", 10, "color merge tolerance")
+	goroutines := fset.Int("goroutines", 1, "goroutines per image")
+	minShare := fset.Float64("min-share", 0, "smallest share of pixels a cluster (density) or color (frequency) needs, 0 for the default")
+	weighting := fset.String("weighting", "", fmt.Sprintf("pixel weighting, one of %v (frequency algorithm)", imageManip.WEIGHTINGS))
+	placeholders := fset.Bool("placeholders", false, "add the BlurHash and ThumbHash of each image")
+	blurX := fset.Int("blurhash-x", imageManip.BLURHASH_X_COMPONENTS, "BlurHash components across, 1 to 9")
+	blurY := fset.Int("blurhash-y", imageManip.BLURHASH_Y_COMPONENTS, "BlurHash components down, 1 to 9")
+	resume := fset.Bool("resume", false, "skip images that already have a row without error in the manifest")
+	useCache := fset.Bool("cache", false, "reuse palettes of unchanged images from the on-disk cache")
+	cacheDir := fset.String("cache-dir", "", "cache directory (default: goPalettes in the user cache directory)")
+	fset.Parse(args)
+
+	if *format == "" {
+		*format = "json"
+		if strings.HasSuffix(*out, ".csv") {
+			*format = "csv"
+		}
+	}
+	if *format != "json" && *format != "csv" {
+		return fmt.Errorf("Unknown manifest format %q.", *format)
+	}
+	if _, err := imageManip.GetExtractor(*algorithm); err != nil {
+		return err
+	}
+	if *workers < 1 {
+		*workers = 1
+	}
+
+	done := make(map[string]bool)
+	if *resume {
+		var err error
+		done, err = readDonePaths(*out, *format)
+		if err != nil {
+			return err
+		}
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if *resume {
+		flags = os.O_CREATE | os.O_RDWR | os.O_APPEND
+	}
+	f, err := os.OpenFile(*out, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if *resume {
+		if err := truncatePartialLine(f); err != nil {
+			return err
+		}
+	}
+
+	var manifest manifestWriter
+	if *format == "csv" {
+		w := csv.NewWriter(f)
+		if info, err := f.Stat(); err == nil && info.Size() == 0 {
+			w.Write(csvHeader)
+		}
+		manifest = csvManifest{w}
+	} else {
+		manifest = jsonManifest{json.NewEncoder(f)}
+	}
+
+	var cache *imageManip.PaletteCache
+	if *useCache || *cacheDir != "" {
+		if *cacheDir == "" {
+			*cacheDir, err = imageManip.DefaultCacheDir()
+			if err != nil {
+				return err
+			}
+		}
+		cache, err = imageManip.NewPaletteCache(1024, *cacheDir, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	opts := imageManip.DefaultOptions()
+	opts.Count = *count
+	opts.Tolerance = *tolerance
+	opts.Goroutines = *goroutines
+	opts.MinShare = *minShare
+	opts.Weighting = *weighting
+
+	var hashes *placeholderComponents
+	if *placeholders {
+		if *blurX < 1 || *blurX > 9 || *blurY < 1 || *blurY > 9 {
+			return errors.New("BlurHash components must be between 1 and 9.")
+		}
+		hashes = &placeholderComponents{*blurX, *blurY}
+	}
+
+	// Ctrl-C stops handing out new images, the ones in progress finish
+	// and are written, so -resume can pick up from there.
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	defer signal.Stop(stop)
+
+	paths := make(chan string)
+	rows := make(chan manifestRow)
+	go func() {
+		defer close(paths)
+		feedPaths(fset.Args(), done, paths, rows, stop)
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(*workers)
+	for i := 0; i < *workers; i++ {
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				rows <- processImage(path, *algorithm, opts, cache, hashes)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(rows)
+	}()
+
+	written, failed := 0, 0
+	for row := range rows {
+		if err := manifest.write(row); err != nil {
+			return err
+		}
+		written++
+		if row.Error != "" {
+			failed++
+		}
+		fmt.Fprintf(os.Stderr, "[%d] %s\n", written, row.Path)
+	}
+	fmt.Fprintf(os.Stderr, "%d images, %d failed, %d done in earlier runs. Manifest: %s\n",
+		written, failed, len(done), *out)
+	if cache != nil {
+		stats := cache.Stats()
+		fmt.Fprintf(os.Stderr, "Cache: %d hits, %d misses.\n", stats.Hits+stats.DiskHits, stats.Misses)
+	}
+	return nil
+}
+
+// Sends every image path from the arguments (or stdin) to paths, skipping
+// the done ones. Directory walk errors go straight to rows.
+func feedPaths(
+	args []string,
+	done map[string]bool,
+	paths chan<- string,
+	rows chan<- manifestRow,
+	stop <-chan os.Signal,
+) {
+	stopped := false
+	send := func(path string) {
+		if stopped || done[path] {
+			return
+		}
+		select {
+		case paths <- path:
+		case <-stop:
+			fmt.Fprintln(os.Stderr, "Interrupted, finishing images in progress.")
+			stopped = true
+		}
+	}
+
+	if len(args) == 0 || (len(args) == 1 && args[0] == "-") {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() && !stopped {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				send(line)
+			}
+		}
+		return
+	}
+
+	for _, root := range args {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if stopped {
+				return filepath.SkipDir
+			}
+			if err != nil {
+				rows <- manifestRow{Path: path, Error: err.Error()}
+				return nil
+			}
+			if !d.IsDir() && hasImageExtension(path) {
+				send(path)
+			}
+			return nil
+		})
+	}
+}
+
+func hasImageExtension(path string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	for _, supported := range imageManip.SUPPORTED_EXTENSIONS {
+		if ext == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// BlurHash components of the placeholders added to manifest rows.
+type placeholderComponents struct {
+	x, y int
+}
+
+// cache may be nil, hashes too when the rows get no placeholders.
+func processImage(
+	path string,
+	algorithm string,
+	opts imageManip.Options,
+	cache *imageManip.PaletteCache,
+	hashes *placeholderComponents,
+) (row manifestRow) {
+	row = manifestRow{Path: path, Algorithm: algorithm}
+	start := time.Now()
+	defer func() {
+		row.Millis = float64(time.Since(start).Microseconds()) / 1000
+	}()
+
+	img, err := imageManip.LoadImage(path)
+	if err != nil {
+		row.Error = err.Error()
+		return row
+	}
+	row.Width, row.Height = img.Bounds().Dx(), img.Bounds().Dy()
+
+	var palette []imageManip.ColAndFreq
+	if cache != nil {
+		palette, row.Cached, err = cache.Extract(algorithm, img, opts)
+	} else {
+		palette, err = imageManip.Extract(algorithm, img, opts)
+	}
+	if err != nil {
+		row.Error = err.Error()
+		return row
+	}
+	row.Palette = palette
+	row.Names = imageManip.NamePalette(palette)
+
+	if hashes != nil {
+		p, err := imageManip.NewPlaceholders(img, hashes.x, hashes.y)
+		if err != nil {
+			row.Error = err.Error()
+			return row
+		}
+		row.BlurHash, row.ThumbHash = p.BlurHash, p.ThumbHash
+	}
+	return row
+}
+
+// Paths that already have a successful row in an existing manifest.
+func readDonePaths(manifestPath string, format string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	f, err := os.Open(manifestPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if format == "csv" {
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				// A run killed mid-write can leave a broken line, the
+				// rows after it still count.
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if len(record) >= 7 && record[0] != "path" && record[6] == "" {
+				done[record[0]] = true
+			}
+		}
+		return done, nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var row manifestRow
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			continue
+		}
+		if row.Error == "" {
+			done[row.Path] = true
+		}
+	}
+	return done, nil
+}
+
+// Cuts off what follows the last newline of the manifest, a row a killed
+// run didn't finish, so appended rows start on a line of their own.
+func truncatePartialLine(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 4096)
+	for end := info.Size(); end > 0; {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+		n, err := f.ReadAt(buf[:end-start], start)
+		if err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			return f.Truncate(start + int64(i) + 1)
+		}
+		end = start
+	}
+	return f.Truncate(0)
+}
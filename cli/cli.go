@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Subcommands available from the command line. Without one goPalettes
+// starts the GUI. Commands write their results to stdout; with GOPALETTES_DEBUG
+// set the extractors also log their progress to stderr.
+var commands = map[string]func(args []string) error{
+	"base16":      runBase16,
+	"batch":       runBatch,
+	"contrast":    runContrast,
+	"cvd":         runCVD,
+	"diff":        runDiff,
+	"export":      runExport,
+	"grid":        runGrid,
+	"placeholder": runPlaceholder,
+	"roles":       runRoles,
+	"search":      runSearch,
+	"serve":       runServe,
+	"terminal":    runTerminal,
+	"transfer":    runTransfer,
+}
+
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok
+}
+
+// args[0] is the command name, the rest are its arguments.
+func Run(args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("Unknown command %q. Available: %v.", args[0], commandNames())
+	}
+	return cmd(args[1:])
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
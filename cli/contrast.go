@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"goPalettes/imageManip"
+	"os"
+	"strings"
+)
+
+// contrast [flags] image
+// Prints the WCAG and APCA contrast matrix of the image's palette as JSON.
+// With -colors the given colors are used instead of an image.
+func runContrast(args []string) error {
+	fset := flag.NewFlagSet("contrast", flag.ExitOnError)
+	colors := fset.String("colors", "", "comma separated hex codes to use instead of an image")
+	ef := addExtractFlags(fset, 5)
+	fset.Parse(args)
+
+	var palette []imageManip.ColAndFreq
+	switch {
+	case *colors != "":
+		var err error
+		palette, err = parseHexList(*colors)
+		if err != nil {
+			return err
+		}
+	case fset.NArg() == 1:
+		var err error
+		palette, err = ef.extract(fset.Arg(0))
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("Usage: goPalettes contrast [flags] image")
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(imageManip.NewContrastMatrix(palette))
+}
+
+// "#aabbcc,ddeeff" -> palette with zero frequencies.
+func parseHexList(list string) ([]imageManip.ColAndFreq, error) {
+	var palette []imageManip.ColAndFreq
+	for _, hex := range strings.Split(list, ",") {
+		hex = strings.ToLower(strings.TrimSpace(hex))
+		if !strings.HasPrefix(hex, "#") {
+			hex = "#" + hex
+		}
+		if len(hex) != 7 || strings.Trim(hex[1:], "0123456789abcdef") != "" {
+			return nil, errors.New("Colors must be 6 digit hex codes like #aabbcc.")
+		}
+		palette = append(palette, imageManip.ColAndFreq{ColString: hex})
+	}
+	return palette, nil
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"goPalettes/imageManip"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// cvd [flags] image
+// Prints the palette as seen with each color vision deficiency and the
+// pairs of colors that become hard to tell apart, as JSON. With -o the
+// simulated images are written to that directory.
+func runCVD(args []string) error {
+	fset := flag.NewFlagSet("cvd", flag.ExitOnError)
+	condition := fset.String("condition", "", fmt.Sprintf("one of %v (default: all)", imageManip.CVD_TYPES))
+	out := fset.String("o", "", "directory to write the simulated images to")
+	ef := addExtractFlags(fset, 5)
+	fset.Parse(args)
+
+	if fset.NArg() != 1 {
+		return errors.New("Usage: goPalettes cvd [flags] image")
+	}
+	path := fset.Arg(0)
+	conditions := imageManip.CVD_TYPES
+	if *condition != "" {
+		conditions = []string{*condition}
+	}
+
+	img, err := imageManip.LoadImage(path)
+	if err != nil {
+		return err
+	}
+	palette, err := imageManip.Extract(*ef.algorithm, img, ef.options())
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		// Only the palette is needed, skip simulating every pixel.
+		img = nil
+	} else if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+
+	sims := make([]imageManip.CVDSimulation, 0, len(conditions))
+	for _, c := range conditions {
+		sim, err := imageManip.SimulateCVDAll(img, palette, c)
+		if err != nil {
+			return err
+		}
+		if sim.Image != nil {
+			if err := writePNG(filepath.Join(*out, schemeName(path)+"-"+c+".png"), sim); err != nil {
+				return err
+			}
+		}
+		sims = append(sims, sim)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sims)
+}
+
+func writePNG(path string, sim imageManip.CVDSimulation) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, sim.Image); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"goPalettes/imageManip"
+	"os"
+	"strings"
+)
+
+// diff [flags] before after
+// Matches the colors of two palettes and prints how each one shifted and
+// which were added or removed. before and after are images, or comma
+// separated hex codes starting with "#". Comparing one image with itself
+// and -after-algorithm or -after-count shows what an option changes.
+func runDiff(args []string) error {
+	fset := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fset.String("format", "text", "text or json")
+	maxDeltaE := fset.Float64("max-delta-e", imageManip.DIFF_MAX_DELTA_E, "largest OKLab distance of a matched pair")
+	afterAlgorithm := fset.String("after-algorithm", "", "extractor for the after image (default: -algorithm)")
+	afterCount := fset.Int("after-count", 0, "colors to extract from the after image (default: -count)")
+	ef := addExtractFlags(fset, 5)
+	fset.Parse(args)
+	if fset.NArg() != 2 {
+		return errors.New("Usage: goPalettes diff [flags] before after")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("Unknown format %q. Available: [text json].", *format)
+	}
+
+	before, err := diffOperand(fset.Arg(0), *ef.algorithm, ef.options())
+	if err != nil {
+		return err
+	}
+	algorithm, opts := *ef.algorithm, ef.options()
+	if *afterAlgorithm != "" {
+		algorithm = *afterAlgorithm
+	}
+	if *afterCount > 0 {
+		opts.Count = *afterCount
+	}
+	after, err := diffOperand(fset.Arg(1), algorithm, opts)
+	if err != nil {
+		return err
+	}
+
+	diff := imageManip.DiffPalettes(before, after, *maxDeltaE)
+	if *format == "text" {
+		return diff.WriteTable(os.Stdout)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(diff)
+}
+
+// Palette of an image, or of a hex list if arg starts with "#".
+func diffOperand(arg string, algorithm string, opts imageManip.Options) ([]imageManip.ColAndFreq, error) {
+	if strings.HasPrefix(arg, "#") {
+		return parseHexList(arg)
+	}
+	img, err := imageManip.LoadImage(arg)
+	if err != nil {
+		return nil, err
+	}
+	return imageManip.Extract(algorithm, img, opts)
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"goPalettes/imageManip"
+	"os"
+)
+
+// export [flags] image
+// Prints the image's palette as CSS, SCSS, Tailwind, a VS Code theme, Go
+// or through a user template.
+func runExport(args []string) error {
+	fset := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fset.String("format", "css", fmt.Sprintf("one of %v, or the path of a text/template file", imageManip.ExportFormats()))
+	name := fset.String("name", "", "palette name (default: image file name)")
+	list := fset.Bool("list", false, "list the available formats and exit")
+	ef := addExtractFlags(fset, 5)
+	fset.Parse(args)
+
+	if *list {
+		for _, f := range imageManip.ExportFormats() {
+			fmt.Printf("%s\t.%s\n", f, imageManip.ExportExtension(f))
+		}
+		if dir, err := imageManip.ExportTemplateDir(); err == nil {
+			fmt.Fprintf(os.Stderr, "User templates are read from %s\n", dir)
+		}
+		return nil
+	}
+	if fset.NArg() != 1 {
+		return errors.New("Usage: goPalettes export [flags] image")
+	}
+	path := fset.Arg(0)
+	if *name == "" {
+		*name = schemeName(path)
+	}
+
+	palette, err := ef.extract(path)
+	if err != nil {
+		return err
+	}
+	return imageManip.WriteExport(os.Stdout, *format, imageManip.NewExportData(*name, palette))
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"goPalettes/imageManip"
+	"path/filepath"
+	"strings"
+)
+
+// Flags shared by the commands that extract a palette from one image.
+type extractFlags struct {
+	algorithm *string
+	count     *int
+	tolerance *float64
+	sample    *int
+	minShare  *float64
+	weighting *string
+	weightMap *string
+}
+
+func addExtractFlags(fset *flag.FlagSet, defaultCount int) *extractFlags {
+	return &extractFlags{
+		algorithm: fset.String("algorithm", imageManip.DEFAULT_EXTRACTOR, fmt.Sprintf("one of %v", imageManip.ExtractorNames())),
+		count:     fset.Int("count", defaultCount, "colors to extract"),
+		tolerance: fset.Float64("tolerance", 10, "color merge tolerance"),
+		sample:    fset.Int("sample", 1, "only look at every n'th pixel in each direction"),
+		minShare:  fset.Float64("min-share", 0, "smallest share of pixels a cluster (density) or color (frequency) needs, 0 for the default"),
+		weighting: fset.String("weighting", "", fmt.Sprintf("pixel weighting, one of %v (frequency algorithm)", imageManip.WEIGHTINGS)),
+		weightMap: fset.String("weight-map", "", "grayscale image weighting the pixels, white counts most (frequency algorithm)"),
+	}
+}
+
+func (f *extractFlags) options() imageManip.Options {
+	opts := imageManip.DefaultOptions()
+	opts.Count = *f.count
+	opts.Tolerance = *f.tolerance
+	opts.Sample = *f.sample
+	opts.MinShare = *f.minShare
+	opts.Weighting = *f.weighting
+	opts.WeightMap = *f.weightMap
+	return opts
+}
+
+func (f *extractFlags) extract(path string) ([]imageManip.ColAndFreq, error) {
+	img, err := imageManip.LoadImage(path)
+	if err != nil {
+		return nil, err
+	}
+	return imageManip.Extract(*f.algorithm, img, f.options())
+}
+
+// Image file name without directory and extension, used to name schemes.
+func schemeName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
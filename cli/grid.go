@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"goPalettes/imageManip"
+	"image/png"
+	"os"
+)
+
+// grid [flags] image
+// Prints the dominant color of each cell of a grid over the image, with
+// CSS gradients and a data URI placeholder, as JSON. -o also writes the
+// one pixel per cell image.
+func runGrid(args []string) error {
+	fset := flag.NewFlagSet("grid", flag.ExitOnError)
+	columns := fset.Int("columns", 4, "grid columns")
+	rows := fset.Int("rows", 4, "grid rows")
+	out := fset.String("o", "", "write the color matrix as a PNG to this file")
+	ef := addExtractFlags(fset, 1)
+	fset.Parse(args)
+	if fset.NArg() != 1 {
+		return errors.New("Usage: goPalettes grid [flags] image")
+	}
+
+	img, err := imageManip.LoadImage(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	p, err := imageManip.ExtractSpatialPalette(img, *ef.algorithm, *columns, *rows, ef.options())
+	if err != nil {
+		return err
+	}
+
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(f, p.Image()); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"goPalettes/imageManip"
+	"image"
+	"image/png"
+	"os"
+)
+
+// placeholder [flags] image
+// Prints the BlurHash and ThumbHash of the image as JSON. With -blurhash
+// or -thumbhash the hash is decoded into the -o PNG instead.
+func runPlaceholder(args []string) error {
+	fset := flag.NewFlagSet("placeholder", flag.ExitOnError)
+	x := fset.Int("x", imageManip.BLURHASH_X_COMPONENTS, "BlurHash components across, 1 to 9")
+	y := fset.Int("y", imageManip.BLURHASH_Y_COMPONENTS, "BlurHash components down, 1 to 9")
+	blurHash := fset.String("blurhash", "", "BlurHash to decode")
+	thumbHash := fset.String("thumbhash", "", "ThumbHash to decode")
+	width := fset.Int("width", 32, "width of a decoded BlurHash")
+	height := fset.Int("height", 32, "height of a decoded BlurHash")
+	punch := fset.Float64("punch", 1, "contrast of a decoded BlurHash")
+	out := fset.String("o", "placeholder.png", "decoded image")
+	fset.Parse(args)
+
+	var img image.Image
+	var err error
+	switch {
+	case *blurHash != "":
+		img, err = imageManip.BlurHashDecode(*blurHash, *width, *height, *punch)
+	case *thumbHash != "":
+		img, err = imageManip.ThumbHashDecode(*thumbHash)
+	case fset.NArg() == 1:
+		src, err := imageManip.LoadImage(fset.Arg(0))
+		if err != nil {
+			return err
+		}
+		p, err := imageManip.NewPlaceholders(src, *x, *y)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(p)
+	default:
+		return errors.New("Usage: goPalettes placeholder [flags] image, or -blurhash/-thumbhash hash -o out.png")
+	}
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"goPalettes/imageManip"
+	"os"
+)
+
+// roles [flags] image
+// Prints the Vibrant / Muted swatches of the image (Android Palette roles)
+// as JSON.
+func runRoles(args []string) error {
+	fset := flag.NewFlagSet("roles", flag.ExitOnError)
+	ef := addExtractFlags(fset, imageManip.ROLE_CANDIDATES)
+	fset.Parse(args)
+	if fset.NArg() != 1 {
+		return errors.New("Usage: goPalettes roles [flags] image")
+	}
+
+	palette, err := ef.extract(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(imageManip.PaletteRoles(palette))
+}
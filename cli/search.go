@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"goPalettes/imageManip"
+	"io"
+	"os"
+	"strings"
+)
+
+// search [flags] manifest [image]
+// Ranks the images of a batch manifest by how close their palette is to
+// the palette of image (or -colors), or with -color by how close their
+// nearest color is. Prints the results as JSON.
+func runSearch(args []string) error {
+	fset := flag.NewFlagSet("search", flag.ExitOnError)
+	single := fset.String("color", "", "find images containing this hex color")
+	colors := fset.String("colors", "", "comma separated hex codes to use as the query palette instead of an image")
+	limit := fset.Int("limit", 10, "results to print, 0 for all")
+	maxDistance := fset.Float64("max-distance", imageManip.SEARCH_MAX_DISTANCE, "largest OKLab distance of a -color match")
+	ef := addExtractFlags(fset, 5)
+	fset.Parse(args)
+	if fset.NArg() < 1 || fset.NArg() > 2 {
+		return errors.New("Usage: goPalettes search [flags] manifest [image]")
+	}
+
+	idx, err := loadPaletteIndex(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var results []imageManip.SearchResult
+	switch {
+	case *single != "":
+		c, err := parseHexList(*single)
+		if err != nil || len(c) != 1 {
+			return errors.New("-color must be one hex code like #aabbcc.")
+		}
+		results = idx.ContainingColor(imageManip.HexToNRGBA(c[0].ColString), *maxDistance, *limit)
+	case *colors != "":
+		query, err := parseHexList(*colors)
+		if err != nil {
+			return err
+		}
+		results = idx.SimilarPalettes(query, *limit)
+	case fset.NArg() == 2:
+		query, err := ef.extract(fset.Arg(1))
+		if err != nil {
+			return err
+		}
+		results = idx.SimilarPalettes(query, *limit)
+	default:
+		return errors.New("Search needs an image, -colors or -color.")
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// Index of a JSON lines or CSV manifest written by the batch command.
+func loadPaletteIndex(path string) (*imageManip.PaletteIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if !strings.HasSuffix(path, ".csv") {
+		return imageManip.ReadPaletteIndex(f)
+	}
+
+	idx := imageManip.NewPaletteIndex()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 7 || record[0] == "path" || record[6] != "" {
+			continue
+		}
+		palette, err := paletteFromCSV(record[4])
+		if err != nil {
+			return nil, err
+		}
+		idx.Add(record[0], palette)
+	}
+	return idx, nil
+}
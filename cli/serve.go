@@ -0,0 +1,517 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"goPalettes/imageManip"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+type serverConfig struct {
+	maxBytes int64
+	// Largest image accepted, checked from the header before decoding.
+	maxPixels int64
+	timeout   time.Duration
+	// Decodes and extractions running at the same time. Requests beyond
+	// this wait for a slot until their timeout runs out.
+	slots chan struct{}
+	cache *imageManip.PaletteCache
+	// Palettes /search looks through, nil without -index.
+	index *imageManip.PaletteIndex
+}
+
+type paletteResponse struct {
+	Algorithm string                  `json:"algorithm"`
+	Width     int                     `json:"width"`
+	Height    int                     `json:"height"`
+	Options   imageManip.Options      `json:"options"`
+	Palette   []imageManip.ColAndFreq `json:"palette"`
+	Names     []imageManip.ColorName  `json:"names"`
+	Millis    float64                 `json:"millis"`
+	Cached    bool                    `json:"cached"`
+
+	image image.Image
+}
+
+// serve [flags]
+// Exposes the extractors over HTTP:
+//
+//	POST   /palette  image as the body or as multipart field "image"
+//	POST   /contrast same as /palette, returns the palette's contrast matrix
+//	POST   /cvd      same as /palette, color vision deficiency simulation
+//	GET    /search   palettes of the -index manifest with a color near color
+//	                 (or a palette near colors), ranked
+//	POST   /search   same as /palette, palettes of the index near the image's
+//	GET    /health
+//	DELETE /cache    empties the palette cache
+//
+// /palette query parameters: count, algorithm, tolerance, sample, minShare,
+// weighting and format (json or one of imageManip.SWATCH_FORMATS). /search
+// also takes limit and maxDistance.
+func runServe(args []string) error {
+	fset := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fset.String("addr", ":8080", "listen address")
+	maxBytes := fset.Int64("max-bytes", 20<<20, "largest accepted upload in bytes")
+	maxPixels := fset.Int64("max-pixels", 50_000_000, "largest accepted image in pixels")
+	timeout := fset.Duration("timeout", 30*time.Second, "time limit per request")
+	concurrency := fset.Int("concurrency", runtime.NumCPU(), "extractions running at the same time")
+	cacheEntries := fset.Int("cache-entries", 256, "palettes kept in memory")
+	cacheDir := fset.String("cache-dir", "", "also keep palettes in this directory")
+	cacheDiskBytes := fset.Int64("cache-disk-bytes", 64<<20, "size cap of the cache directory")
+	indexPath := fset.String("index", "", "batch manifest to answer /search from")
+	fset.Parse(args)
+
+	if *concurrency < 1 {
+		*concurrency = 1
+	}
+	cache, err := imageManip.NewPaletteCache(*cacheEntries, *cacheDir, *cacheDiskBytes)
+	if err != nil {
+		return err
+	}
+	cfg := &serverConfig{
+		maxBytes:  *maxBytes,
+		maxPixels: *maxPixels,
+		timeout:   *timeout,
+		slots:     make(chan struct{}, *concurrency),
+		cache:     cache,
+	}
+	if *indexPath != "" {
+		cfg.index, err = loadPaletteIndex(*indexPath)
+		if err != nil {
+			return err
+		}
+		log.Printf("Indexed %d palettes from %s\n", cfg.index.Len(), *indexPath)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", cfg.handleHealth)
+	mux.HandleFunc("/palette", cfg.handlePalette)
+	mux.HandleFunc("/contrast", cfg.handleContrast)
+	mux.HandleFunc("/cvd", cfg.handleCVD)
+	mux.HandleFunc("/cache", cfg.handleCache)
+	mux.HandleFunc("/search", cfg.handleSearch)
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("Listening on %s\n", *addr)
+	return server.ListenAndServe()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (cfg *serverConfig) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":     "ok",
+		"algorithms": imageManip.ExtractorNames(),
+		"formats":    append([]string{"json"}, imageManip.SWATCH_FORMATS...),
+		"inFlight":   len(cfg.slots),
+		"capacity":   cap(cfg.slots),
+		"cache":      cfg.cache.Stats(),
+	})
+}
+
+func (cfg *serverConfig) handleCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.Header().Set("Allow", http.MethodDelete)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("Use DELETE."))
+		return
+	}
+	if err := cfg.cache.Clear(); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cfg.cache.Stats())
+}
+
+func (cfg *serverConfig) handlePalette(w http.ResponseWriter, r *http.Request) {
+	res, format, ok := cfg.uploadPalette(w, r)
+	if !ok {
+		return
+	}
+	if format != "json" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=palette."+format)
+		imageManip.WriteSwatches(w, format, "goPalettes", res.Palette)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// Same input as /palette, answers with the contrast matrix of the palette.
+func (cfg *serverConfig) handleContrast(w http.ResponseWriter, r *http.Request) {
+	res, _, ok := cfg.uploadPalette(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, imageManip.NewContrastMatrix(res.Palette))
+}
+
+// Same input as /palette plus condition, answers with the palette as seen
+// with each color vision deficiency (or just condition) and its conflicts.
+// With image=1 and a condition, the simulated image is returned as PNG.
+func (cfg *serverConfig) handleCVD(w http.ResponseWriter, r *http.Request) {
+	conditions := imageManip.CVD_TYPES
+	if c := r.URL.Query().Get("condition"); c != "" {
+		if _, err := imageManip.SimulateCVD(color.NRGBA{}, c); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		conditions = []string{c}
+	}
+	wantImage := r.URL.Query().Get("image") == "1"
+	if wantImage && len(conditions) != 1 {
+		writeError(w, http.StatusBadRequest, errors.New("image=1 needs a condition."))
+		return
+	}
+
+	res, _, ok := cfg.uploadPalette(w, r)
+	if !ok {
+		return
+	}
+	if wantImage {
+		sim, err := imageManip.SimulateCVDAll(res.image, res.Palette, conditions[0])
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		png.Encode(w, sim.Image)
+		return
+	}
+
+	sims := make([]imageManip.CVDSimulation, 0, len(conditions))
+	for _, c := range conditions {
+		sim, err := imageManip.SimulateCVDAll(nil, res.Palette, c)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		sims = append(sims, sim)
+	}
+	writeJSON(w, http.StatusOK, sims)
+}
+
+func (cfg *serverConfig) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if cfg.index == nil {
+		writeError(w, http.StatusNotFound, errors.New("No index, start the server with -index."))
+		return
+	}
+	q := r.URL.Query()
+	limit := 10
+	if v := q.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, errors.New("limit must be a positive integer."))
+			return
+		}
+	}
+
+	if r.Method == http.MethodPost {
+		res, _, ok := cfg.uploadPalette(w, r)
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, cfg.index.SimilarPalettes(res.Palette, limit))
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("Use GET or POST."))
+		return
+	}
+
+	switch {
+	case q.Get("color") != "":
+		c, err := parseHexList(q.Get("color"))
+		if err != nil || len(c) != 1 {
+			writeError(w, http.StatusBadRequest, errors.New("color must be one hex code like #aabbcc."))
+			return
+		}
+		maxDistance := imageManip.SEARCH_MAX_DISTANCE
+		if v := q.Get("maxDistance"); v != "" {
+			maxDistance, err = strconv.ParseFloat(v, 64)
+			if err != nil || maxDistance <= 0 {
+				writeError(w, http.StatusBadRequest, errors.New("maxDistance must be a positive number."))
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, cfg.index.ContainingColor(imageManip.HexToNRGBA(c[0].ColString), maxDistance, limit))
+	case q.Get("colors") != "":
+		query, err := parseHexList(q.Get("colors"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, cfg.index.SimilarPalettes(query, limit))
+	default:
+		writeError(w, http.StatusBadRequest, errors.New("Give color or colors, or POST an image."))
+	}
+}
+
+// Extracts the palette of a POSTed image. On failure the error response
+// has already been written and ok is false.
+func (cfg *serverConfig) uploadPalette(w http.ResponseWriter, r *http.Request) (res paletteResponse, format string, ok bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, errors.New("Use POST."))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), cfg.timeout)
+	defer cancel()
+
+	algorithm, opts, format, err := parsePaletteQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tooLarge := fmt.Errorf("Upload is larger than the %d bytes allowed.", cfg.maxBytes)
+	if r.ContentLength > cfg.maxBytes {
+		writeError(w, http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+	body := &limitedBody{ReadCloser: r.Body, left: cfg.maxBytes}
+	r.Body = body
+	data, err := readUpload(r)
+	if err != nil {
+		status := http.StatusBadRequest
+		if body.tooLarge {
+			status, err = http.StatusRequestEntityTooLarge, tooLarge
+		}
+		writeError(w, status, err)
+		return
+	}
+
+	// A small file can claim a huge image, so the size is checked before
+	// anything is allocated for the pixels.
+	width, height, err := imageManip.ImageSize(data)
+	if err != nil {
+		writeError(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+	if int64(width)*int64(height) > cfg.maxPixels {
+		writeError(w, http.StatusRequestEntityTooLarge,
+			fmt.Errorf("Image is %dx%d, more than the %d pixels allowed.", width, height, cfg.maxPixels))
+		return
+	}
+
+	start := time.Now()
+	ext := cfg.extract(ctx, data, algorithm, opts)
+	if ext.err != nil {
+		writeError(w, ext.status, ext.err)
+		return
+	}
+
+	return paletteResponse{
+		Algorithm: algorithm,
+		Width:     ext.img.Bounds().Dx(),
+		Height:    ext.img.Bounds().Dy(),
+		Options:   opts,
+		Palette:   ext.palette,
+		Names:     imageManip.NamePalette(ext.palette),
+		Millis:    float64(time.Since(start).Microseconds()) / 1000,
+		Cached:    ext.cached,
+		image:     ext.img,
+	}, format, true
+}
+
+type extraction struct {
+	img     image.Image
+	palette []imageManip.ColAndFreq
+	cached  bool
+	err     error
+	// HTTP status to answer err with.
+	status int
+}
+
+// Waits for a free slot, then decodes the image and looks its palette up
+// in the cache or runs the extractor, giving up when ctx ends. Work that
+// is already running can't be interrupted, it keeps its slot until it
+// finishes so the limit on CPU and memory use holds.
+func (cfg *serverConfig) extract(
+	ctx context.Context,
+	data []byte,
+	algorithm string,
+	opts imageManip.Options,
+) extraction {
+	select {
+	case cfg.slots <- struct{}{}:
+	case <-ctx.Done():
+		return extraction{err: fmt.Errorf("Server busy: %w", ctx.Err()), status: http.StatusServiceUnavailable}
+	}
+
+	done := make(chan extraction, 1)
+	go func() {
+		defer func() { <-cfg.slots }()
+		img, err := imageManip.LoadImage(data)
+		if err != nil {
+			done <- extraction{err: err, status: http.StatusUnsupportedMediaType}
+			return
+		}
+		key := imageManip.CacheKey(imageManip.ImageHash(img), algorithm, opts)
+		if palette, ok := cfg.cache.Get(key); ok {
+			done <- extraction{img: img, palette: palette, cached: true}
+			return
+		}
+		palette, err := imageManip.Extract(algorithm, img, opts)
+		if err != nil {
+			done <- extraction{err: err, status: http.StatusInternalServerError}
+			return
+		}
+		cfg.cache.Put(key, palette)
+		done <- extraction{img: img, palette: palette}
+	}()
+
+	select {
+	case res := <-done:
+		return res
+	case <-ctx.Done():
+		status := http.StatusServiceUnavailable
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+		}
+		return extraction{err: fmt.Errorf("Extraction timed out: %w", ctx.Err()), status: status}
+	}
+}
+
+func parsePaletteQuery(r *http.Request) (algorithm string, opts imageManip.Options, format string, err error) {
+	q := r.URL.Query()
+	opts = imageManip.DefaultOptions()
+	opts.Goroutines = 1
+
+	algorithm = imageManip.DEFAULT_EXTRACTOR
+	if v := q.Get("algorithm"); v != "" {
+		algorithm = v
+	}
+	if _, err = imageManip.GetExtractor(algorithm); err != nil {
+		return
+	}
+
+	if v := q.Get("count"); v != "" {
+		opts.Count, err = strconv.Atoi(v)
+		if err != nil || opts.Count < 1 || opts.Count > 256 {
+			err = errors.New("count must be between 1 and 256.")
+			return
+		}
+	}
+	if v := q.Get("tolerance"); v != "" {
+		opts.Tolerance, err = strconv.ParseFloat(v, 64)
+		if err != nil || opts.Tolerance < 0 {
+			err = errors.New("tolerance must be a positive number.")
+			return
+		}
+	}
+	if v := q.Get("minShare"); v != "" {
+		opts.MinShare, err = strconv.ParseFloat(v, 64)
+		if err != nil || opts.MinShare < 0 || opts.MinShare >= 1 {
+			err = errors.New("minShare must be between 0 and 1.")
+			return
+		}
+	}
+	if v := q.Get("weighting"); v != "" {
+		opts.Weighting = v
+		if err = imageManip.CheckWeighting(v); err != nil {
+			return
+		}
+	}
+	if v := q.Get("sample"); v != "" {
+		opts.Sample, err = strconv.Atoi(v)
+		if err != nil || opts.Sample < 0 {
+			err = errors.New("sample must be a positive integer.")
+			return
+		}
+	}
+
+	format = "json"
+	if v := q.Get("format"); v != "" {
+		format = v
+	}
+	if format != "json" {
+		err = imageManip.WriteSwatches(ioutil.Discard, format, "", nil)
+	}
+	return
+}
+
+// Request body that fails reads past left bytes, like
+// http.MaxBytesReader, and remembers that it did. Multipart parsing
+// doesn't keep the read error, so the flag is what tells an upload that
+// is too large from a malformed one.
+type limitedBody struct {
+	io.ReadCloser
+	left     int64
+	tooLarge bool
+}
+
+var errBodyTooLarge = errors.New("Request body too large.")
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.tooLarge {
+		return 0, errBodyTooLarge
+	}
+	// One byte more than allowed, to see whether the body ends in time.
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.left {
+		b.left -= int64(n)
+		return n, err
+	}
+	n = int(b.left)
+	b.left = 0
+	b.tooLarge = true
+	return n, errBodyTooLarge
+}
+
+// Body of the request, or the "image" field of a multipart upload.
+func readUpload(r *http.Request) ([]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return ioutil.ReadAll(r.Body)
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("No \"image\" field in multipart upload.")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "image" {
+			defer part.Close()
+			return ioutil.ReadAll(part)
+		}
+		part.Close()
+	}
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"goPalettes/imageManip"
+	"os"
+)
+
+// terminal [flags] image
+// Prints a 16 color terminal scheme built from the image's palette.
+func runTerminal(args []string) error {
+	fset := flag.NewFlagSet("terminal", flag.ExitOnError)
+	format := fset.String("format", "alacritty", fmt.Sprintf("one of %v", imageManip.TERMINAL_FORMATS))
+	light := fset.Bool("light", false, "light background")
+	name := fset.String("name", "", "scheme name (default: image file name)")
+	ef := addExtractFlags(fset, 8)
+	fset.Parse(args)
+
+	if fset.NArg() != 1 {
+		return errors.New("Usage: goPalettes terminal [flags] image")
+	}
+	path := fset.Arg(0)
+	if *name == "" {
+		*name = schemeName(path)
+	}
+
+	palette, err := ef.extract(path)
+	if err != nil {
+		return err
+	}
+	scheme := imageManip.GenerateTerminalScheme(*name, palette, *light)
+	return imageManip.WriteTerminalScheme(os.Stdout, *format, scheme)
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"goPalettes/imageManip"
+	"image/png"
+	"os"
+	"strings"
+)
+
+// transfer [flags] source target
+// Recolors the source image with the palette of target, which is an
+// image, a .gpl file or comma separated hex codes starting with "#", and
+// writes the result as a PNG.
+func runTransfer(args []string) error {
+	fset := flag.NewFlagSet("transfer", flag.ExitOnError)
+	method := fset.String("method", imageManip.TRANSFER_REINHARD, fmt.Sprintf("one of %v", imageManip.TRANSFER_METHODS))
+	out := fset.String("o", "transfer.png", "output PNG")
+	ef := addExtractFlags(fset, 5)
+	fset.Parse(args)
+	if fset.NArg() != 2 {
+		return errors.New("Usage: goPalettes transfer [flags] source target")
+	}
+
+	img, err := imageManip.LoadImage(fset.Arg(0))
+	if err != nil {
+		return err
+	}
+	var target []imageManip.ColAndFreq
+	if strings.HasPrefix(fset.Arg(1), "#") {
+		target, err = parseHexList(fset.Arg(1))
+	} else {
+		target, err = imageManip.LoadPalette(fset.Arg(1), *ef.algorithm, ef.options())
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := imageManip.TransferPalette(img, target, *method, *ef.algorithm, ef.options())
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, result); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
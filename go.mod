@@ -5,4 +5,5 @@ go 1.16
 require (
 	gioui.org v0.0.0-20230101161950-e9bce02b24f0
 	github.com/sqweek/dialog v0.0.0-20220809060634-e981b270ebbf
+	golang.org/x/image v0.0.0-20220722155232-062f8c9fd539
 )
@@ -0,0 +1,237 @@
+package imageManip
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// A Base16 or Base24 scheme as described by the base16 styling guide.
+// Colors holds base00 to base0F, followed by base10 to base17 for Base24.
+type Base16Scheme struct {
+	Scheme  string
+	Author  string
+	Variant string
+	Colors  []color.NRGBA
+}
+
+const (
+	BASE16_DARK  = "dark"
+	BASE16_LIGHT = "light"
+)
+
+// Position of base00 to base07 between the background and the lightest
+// foreground. Mirrors the spacing of the default base16 schemes.
+var base16Ramp = [8]float64{0, 0.08, 0.18, 0.38, 0.58, 0.78, 0.9, 1}
+
+// OKLCH hues of base08 (red) to base0F (brown). Brown shares orange's
+// hue and is made darker.
+var base16AccentHues = [8]float64{29, 55, 100, 142, 195, 264, 328, 55}
+
+// Palette colors within this many degrees of an accent's hue can be used
+// for it.
+const BASE16_HUE_RANGE = 30
+
+// Accents below this chroma are greys and go to the ramp instead.
+const BASE16_MIN_ACCENT_CHROMA = 0.04
+
+// Builds a Base16 (or, with base24, a Base24) scheme from palette.
+// base00 to base07 run from the background to the foreground, using the
+// lightness of the palette's greyest colors where they fit and
+// interpolating in OKLCH between them otherwise. base08 to base0F are the
+// palette colors nearest to the usual accent hues; accents the palette
+// has no color for are interpolated in OKLCH from the closest accents on
+// either side.
+func GenerateBase16(scheme, author string, palette []ColAndFreq, variant string, base24 bool) (Base16Scheme, error) {
+	if variant != BASE16_DARK && variant != BASE16_LIGHT {
+		return Base16Scheme{}, fmt.Errorf("Unknown variant %q, use %q or %q.", variant, BASE16_DARK, BASE16_LIGHT)
+	}
+	light := variant == BASE16_LIGHT
+
+	lchs := make([]OKLCH, len(palette))
+	for i, c := range palette {
+		lchs[i] = NRGBAToOKLCH(HexToNRGBA(c.ColString))
+	}
+
+	ramp := base16Ramp8(lchs, light)
+	accents := base16Accents(lchs, light)
+
+	colors := make([]color.NRGBA, 0, 24)
+	for _, lch := range ramp {
+		colors = append(colors, OKLCHToNRGBA(lch))
+	}
+	bg := colors[0]
+	for _, lch := range accents {
+		colors = append(colors, EnsureContrast(OKLCHToNRGBA(lch), bg, 3))
+	}
+
+	if base24 {
+		// base10 and base11 continue the ramp past the background, base12
+		// to base17 are brighter red, yellow, green, cyan, blue, magenta.
+		step := ramp[1].L - ramp[0].L
+		for i := 1; i <= 2; i++ {
+			l := ramp[0].L - step*float64(i)
+			if light {
+				l = ramp[0].L + step*float64(i)
+			}
+			colors = append(colors, OKLCHToNRGBA(OKLCH{L: clamp01(l), C: ramp[0].C, H: ramp[0].H}))
+		}
+		for _, i := range []int{0, 2, 3, 4, 5, 6} {
+			bright := accents[i]
+			if light {
+				bright.L -= 0.08
+			} else {
+				bright.L += 0.08
+			}
+			bright.C *= 1.1
+			colors = append(colors, EnsureContrast(OKLCHToNRGBA(bright), bg, 3))
+		}
+	}
+
+	return Base16Scheme{
+		Scheme:  scheme,
+		Author:  author,
+		Variant: variant,
+		Colors:  colors,
+	}, nil
+}
+
+// base00 to base07.
+func base16Ramp8(lchs []OKLCH, light bool) [8]OKLCH {
+	byLightness := make([]OKLCH, len(lchs))
+	copy(byLightness, lchs)
+	sort.Slice(byLightness, func(i, j int) bool {
+		return byLightness[i].L < byLightness[j].L
+	})
+
+	darkest, lightest := OKLCH{L: 0.2}, OKLCH{L: 0.95}
+	if len(byLightness) > 0 {
+		darkest = byLightness[0]
+		lightest = byLightness[len(byLightness)-1]
+	}
+	// Keep the ends readable and only faintly tinted.
+	darkest = OKLCH{L: math.Min(darkest.L, 0.24), C: math.Min(darkest.C, 0.03), H: darkest.H}
+	lightest = OKLCH{L: math.Max(lightest.L, 0.9), C: math.Min(lightest.C, 0.03), H: lightest.H}
+
+	bg, fg := darkest, lightest
+	if light {
+		bg, fg = lightest, darkest
+		bg.L = math.Max(bg.L, 0.96)
+	}
+
+	var ramp [8]OKLCH
+	for i, t := range base16Ramp {
+		ramp[i] = MixOKLCH(bg, fg, t)
+	}
+
+	// Greyish palette colors replace the interpolated step closest to
+	// their lightness.
+	used := make(map[int]bool)
+	for i := 1; i < 7; i++ {
+		best := -1
+		for j, lch := range lchs {
+			if used[j] || lch.C > 0.06 || math.Abs(lch.L-ramp[i].L) > 0.04 {
+				continue
+			}
+			if best < 0 || math.Abs(lch.L-ramp[i].L) < math.Abs(lchs[best].L-ramp[i].L) {
+				best = j
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+			ramp[i] = OKLCH{L: ramp[i].L, C: math.Min(lchs[best].C, 0.04), H: lchs[best].H}
+		}
+	}
+	return ramp
+}
+
+// base08 to base0F.
+func base16Accents(lchs []OKLCH, light bool) [8]OKLCH {
+	accentL := 0.7
+	if light {
+		accentL = 0.52
+	}
+
+	var accents [8]OKLCH
+	var found [8]bool
+	used := make(map[int]bool)
+	// Brown is derived from orange afterwards.
+	for i := 0; i < 7; i++ {
+		best := -1
+		for j, lch := range lchs {
+			if used[j] || lch.C < BASE16_MIN_ACCENT_CHROMA ||
+				hueDistance(lch.H, base16AccentHues[i]) > BASE16_HUE_RANGE {
+				continue
+			}
+			if best < 0 || hueDistance(lch.H, base16AccentHues[i]) < hueDistance(lchs[best].H, base16AccentHues[i]) {
+				best = j
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+			found[i] = true
+			accents[i] = OKLCH{
+				L: (lchs[best].L + accentL) / 2,
+				C: math.Max(lchs[best].C, 0.08),
+				H: lchs[best].H,
+			}
+		}
+	}
+
+	anyFound := false
+	for i := 0; i < 7; i++ {
+		anyFound = anyFound || found[i]
+	}
+	for i := 0; i < 7; i++ {
+		if found[i] {
+			continue
+		}
+		if !anyFound {
+			accents[i] = OKLCH{L: accentL, C: 0.12, H: base16AccentHues[i]}
+			continue
+		}
+		// Nearest found accents before and after this one, going round.
+		prev, next := i, i
+		for d := 1; d < 7; d++ {
+			if p := (i - d + 7) % 7; prev == i && found[p] {
+				prev = p
+			}
+			if n := (i + d) % 7; next == i && found[n] {
+				next = n
+			}
+		}
+		distPrev := hueDistance(base16AccentHues[prev], base16AccentHues[i])
+		distNext := hueDistance(base16AccentHues[i], base16AccentHues[next])
+		t := 0.5
+		if distPrev+distNext > 0 {
+			t = distPrev / (distPrev + distNext)
+		}
+		mixed := MixOKLCH(accents[prev], accents[next], t)
+		// Keep the slot's own hue, only borrow lightness and chroma.
+		accents[i] = OKLCH{L: mixed.L, C: mixed.C, H: base16AccentHues[i]}
+	}
+
+	orange := accents[1]
+	accents[7] = OKLCH{L: orange.L * 0.75, C: orange.C * 0.7, H: orange.H}
+	return accents
+}
+
+// Writes the scheme as YAML in the format base16 template builders read,
+// with each color's nearest name as a comment.
+func WriteBase16(w io.Writer, s Base16Scheme) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "scheme: %q\n", s.Scheme)
+	fmt.Fprintf(&b, "author: %q\n", s.Author)
+	if s.Variant != "" {
+		fmt.Fprintf(&b, "variant: %q\n", s.Variant)
+	}
+	for i, c := range s.Colors {
+		name, _ := NearestColorName(c, COLOR_NAMES_ALL)
+		fmt.Fprintf(&b, "base%02X: %q # %s\n", i, strings.TrimPrefix(NRGBAToHex(c), "#"), name.Label())
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
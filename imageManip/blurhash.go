@@ -0,0 +1,203 @@
+package imageManip
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// BlurHash (https://blurha.sh): the DC and the first few cosine
+// components of the image, quantized and written in base 83.
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Default number of components, 4 across and 3 down like the reference
+// implementation.
+const (
+	BLURHASH_X_COMPONENTS = 4
+	BLURHASH_Y_COMPONENTS = 3
+)
+
+func encode83(value, length int) string {
+	var sb strings.Builder
+	for i := length - 1; i >= 0; i-- {
+		digit := value / int(math.Pow(83, float64(i))) % 83
+		sb.WriteByte(base83Chars[digit])
+	}
+	return sb.String()
+}
+
+func decode83(s string) (int, error) {
+	value := 0
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(base83Chars, s[i])
+		if digit < 0 {
+			return 0, fmt.Errorf("In decode83: Invalid character %q.", s[i])
+		}
+		value = value*83 + digit
+	}
+	return value, nil
+}
+
+// Sign preserving power.
+func signPow(v, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(v), exp), v)
+}
+
+// BlurHash of img with xComponents by yComponents cosine components,
+// each between 1 and 9. The image is shrunk first, the hash only keeps
+// low frequencies anyway.
+func BlurHashEncode(img image.Image, xComponents, yComponents int) (string, error) {
+	if xComponents < 1 || xComponents > 9 || yComponents < 1 || yComponents > 9 {
+		return "", errors.New("In BlurHashEncode: Components must be between 1 and 9.")
+	}
+	src := thumbnail(img, 64)
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	if width == 0 || height == 0 {
+		return "", errors.New("In BlurHashEncode: Empty image.")
+	}
+
+	linear := make([][3]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := src.PixOffset(x, y)
+			linear[y*width+x] = [3]float64{
+				srgbToLinear(float64(src.Pix[i]) / 255),
+				srgbToLinear(float64(src.Pix[i+1]) / 255),
+				srgbToLinear(float64(src.Pix[i+2]) / 255),
+			}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1
+			}
+			var f [3]float64
+			for y := 0; y < height; y++ {
+				cosY := math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
+				for x := 0; x < width; x++ {
+					basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) * cosY
+					px := linear[y*width+x]
+					f[0] += basis * px[0]
+					f[1] += basis * px[1]
+					f[2] += basis * px[2]
+				}
+			}
+			scale := normalisation / float64(width*height)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(encode83((xComponents-1)+(yComponents-1)*9, 1))
+
+	maximumValue := 1.0
+	if len(factors) > 1 {
+		actualMax := 0.0
+		for _, f := range factors[1:] {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maximumValue = float64(quantisedMax+1) / 166
+		sb.WriteString(encode83(quantisedMax, 1))
+	} else {
+		sb.WriteString(encode83(0, 1))
+	}
+
+	dc := factors[0]
+	sb.WriteString(encode83(
+		int(to8Bit(linearToSrgb(dc[0])))<<16+int(to8Bit(linearToSrgb(dc[1])))<<8+int(to8Bit(linearToSrgb(dc[2]))),
+		4,
+	))
+
+	for _, f := range factors[1:] {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximumValue, 0.5)*9+9.5))))
+		}
+		sb.WriteString(encode83(quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2))
+	}
+	return sb.String(), nil
+}
+
+// Renders a BlurHash at width x height. punch above 1 makes the colors
+// more contrasted, 1 is neutral.
+func BlurHashDecode(hash string, width, height int, punch float64) (image.Image, error) {
+	if len(hash) < 6 {
+		return nil, errors.New("In BlurHashDecode: Hash is too short.")
+	}
+	if width < 1 || height < 1 {
+		return nil, errors.New("In BlurHashDecode: Size must be at least 1x1.")
+	}
+	if punch <= 0 {
+		punch = 1
+	}
+
+	sizeFlag, err := decode83(hash[:1])
+	if err != nil {
+		return nil, err
+	}
+	numX := sizeFlag%9 + 1
+	numY := sizeFlag/9 + 1
+	if len(hash) != 4+2*numX*numY {
+		return nil, fmt.Errorf("In BlurHashDecode: Hash should be %d characters long, not %d.", 4+2*numX*numY, len(hash))
+	}
+
+	quantisedMax, err := decode83(hash[1:2])
+	if err != nil {
+		return nil, err
+	}
+	maximumValue := float64(quantisedMax+1) / 166 * punch
+
+	colors := make([][3]float64, numX*numY)
+	dc, err := decode83(hash[2:6])
+	if err != nil {
+		return nil, err
+	}
+	colors[0] = [3]float64{
+		srgbToLinear(float64(dc>>16) / 255),
+		srgbToLinear(float64(dc>>8&255) / 255),
+		srgbToLinear(float64(dc&255) / 255),
+	}
+	for i := 1; i < len(colors); i++ {
+		ac, err := decode83(hash[4+i*2 : 6+i*2])
+		if err != nil {
+			return nil, err
+		}
+		colors[i] = [3]float64{
+			signPow(float64(ac/(19*19)-9)/9, 2) * maximumValue,
+			signPow(float64(ac/19%19-9)/9, 2) * maximumValue,
+			signPow(float64(ac%19-9)/9, 2) * maximumValue,
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var c [3]float64
+			for j := 0; j < numY; j++ {
+				cosY := math.Cos(math.Pi * float64(y) * float64(j) / float64(height))
+				for i := 0; i < numX; i++ {
+					basis := math.Cos(math.Pi*float64(x)*float64(i)/float64(width)) * cosY
+					f := colors[i+j*numX]
+					c[0] += f[0] * basis
+					c[1] += f[1] * basis
+					c[2] += f[2] * basis
+				}
+			}
+			img.SetNRGBA(x, y, color.NRGBA{
+				to8Bit(linearToSrgb(clamp01(c[0]))),
+				to8Bit(linearToSrgb(clamp01(c[1]))),
+				to8Bit(linearToSrgb(clamp01(c[2]))),
+				255,
+			})
+		}
+	}
+	return img, nil
+}
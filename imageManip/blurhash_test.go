@@ -0,0 +1,80 @@
+package imageManip
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// Red and green ramps with a pattern in blue, and in alpha when asked.
+// The expected hashes were computed from the same pixels with the
+// reference implementations.
+func testImage(w, h int, alpha bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{uint8(x * 255 / (w - 1)), uint8(y * 255 / (h - 1)), uint8(x * y * 7 % 256), 255}
+			if alpha {
+				c.A = uint8(40 + (x*13+y*29)%216)
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestBlurHashEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		x, y int
+		want string
+	}{
+		{"4x3", testImage(32, 24, false), 4, 3, "L$HewW2owuX4l_WBjse.gGfhfPfi"},
+		{"1x1", testImage(32, 24, false), 1, 1, "00HewW"},
+		{"5x4 portrait", testImage(20, 30, true), 5, 4, "V$HoE@2.wubqWml^WBjre:fPgGfifOfjfRniWnjvfPfS"},
+	}
+	for _, tt := range tests {
+		got, err := BlurHashEncode(tt.img, tt.x, tt.y)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Hashes from the BlurHash README, pixels from the reference decoder.
+func TestBlurHashDecode(t *testing.T) {
+	tests := []struct {
+		hash          string
+		width, height int
+		// Top left, center and bottom right pixel.
+		want [3]color.NRGBA
+	}{
+		{"LEHV6nWB2yk8pyo0adR*.7kCMdnj", 32, 32, [3]color.NRGBA{{135, 164, 177, 255}, {158, 125, 108, 255}, {133, 142, 147, 255}}},
+		{"LGF5]+Yk^6#M@-5c,1J5@[or[Q6.", 32, 24, [3]color.NRGBA{{176, 118, 163, 255}, {107, 126, 130, 255}, {142, 97, 99, 255}}},
+	}
+	for _, tt := range tests {
+		img, err := BlurHashDecode(tt.hash, tt.width, tt.height, 1)
+		if err != nil {
+			t.Errorf("%s: %v", tt.hash, err)
+			continue
+		}
+		points := []image.Point{{0, 0}, {tt.width / 2, tt.height / 2}, {tt.width - 1, tt.height - 1}}
+		for i, p := range points {
+			got := color.NRGBAModel.Convert(img.At(p.X, p.Y)).(color.NRGBA)
+			if !nearNRGBA(got, tt.want[i]) {
+				t.Errorf("%s at %v: got %v, want %v", tt.hash, p, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestBlurHashDecodeErrors(t *testing.T) {
+	for _, hash := range []string{"", "LEHV6", "LEHV6nWB2yk8pyo0adR*.7kCMdn", "LEHV6nWB2yk8pyo0adR*.7kCMdnj!"} {
+		if _, err := BlurHashDecode(hash, 32, 32, 1); err == nil {
+			t.Errorf("%q: no error", hash)
+		}
+	}
+}
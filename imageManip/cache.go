@@ -0,0 +1,282 @@
+package imageManip
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Caches extraction results by image content and options, so the same
+// pixels are never scanned twice with the same settings.
+type PaletteCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	// Front is the most recently used entry.
+	lru *list.List
+
+	// Optional on-disk store, one JSON file per key. Empty dir disables it.
+	dir          string
+	maxDiskBytes int64
+
+	stats CacheStats
+}
+
+type CacheStats struct {
+	Hits     int `json:"hits"`
+	DiskHits int `json:"diskHits"`
+	Misses   int `json:"misses"`
+	Entries  int `json:"entries"`
+}
+
+type cacheEntry struct {
+	key     string
+	palette []ColAndFreq
+}
+
+// maxEntries caps the in-memory LRU. dir enables the disk store, which is
+// pruned (oldest files first) to stay under maxDiskBytes; 0 means no cap.
+func NewPaletteCache(maxEntries int, dir string, maxDiskBytes int64) (*PaletteCache, error) {
+	if maxEntries < 1 {
+		maxEntries = 1
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &PaletteCache{
+		maxEntries:   maxEntries,
+		entries:      make(map[string]*list.Element),
+		lru:          list.New(),
+		dir:          dir,
+		maxDiskBytes: maxDiskBytes,
+	}, nil
+}
+
+// goPalettes folder in the user cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "goPalettes"), nil
+}
+
+// Hex encoded SHA-256 of the image size and its pixels as 8-bit NRGBA.
+// Images that decode to the same pixels hash the same regardless of
+// their file format.
+func ImageHash(img image.Image) string {
+	h := sha256.New()
+	b := img.Bounds()
+	var size [8]byte
+	binary.BigEndian.PutUint32(size[:4], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(size[4:], uint32(b.Dy()))
+	h.Write(size[:])
+
+	if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Rect.Min == (image.Point{}) && nrgba.Stride == 4*b.Dx() {
+		h.Write(nrgba.Pix)
+	} else {
+		h.Write(toNRGBA(img).Pix)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Cache key for an image hash, algorithm and options. Options that mean
+// the same thing (sample 0 and 1 for example) give the same key. The
+// weight map counts by its contents, so editing the file changes the key.
+func CacheKey(imageHash string, algorithm string, opts Options) string {
+	if opts.Sample < 1 {
+		opts.Sample = 1
+	}
+	if opts.Goroutines < 1 {
+		opts.Goroutines = 1
+	}
+	// A file that can't be read keeps its path, the extraction fails on it
+	// and nothing gets cached.
+	if opts.WeightMap != "" {
+		if data, err := ioutil.ReadFile(opts.WeightMap); err == nil {
+			sum := sha256.Sum256(data)
+			opts.WeightMap = hex.EncodeToString(sum[:])
+		}
+	}
+	optsJSON, _ := json.Marshal(opts)
+
+	h := sha256.New()
+	h.Write([]byte(imageHash))
+	h.Write([]byte{0})
+	h.Write([]byte(algorithm))
+	h.Write([]byte{0})
+	h.Write(optsJSON)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Returns the cached palette for key, looking at memory first and then
+// the disk store.
+func (c *PaletteCache) Get(key string) ([]ColAndFreq, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(el)
+		c.stats.Hits++
+		return copyColAndFreqs(el.Value.(*cacheEntry).palette), true
+	}
+
+	if c.dir != "" {
+		data, err := ioutil.ReadFile(c.diskPath(key))
+		var palette []ColAndFreq
+		if err == nil && json.Unmarshal(data, &palette) == nil {
+			c.stats.DiskHits++
+			c.addLocked(key, palette)
+			return copyColAndFreqs(palette), true
+		}
+	}
+
+	c.stats.Misses++
+	return nil, false
+}
+
+func (c *PaletteCache) Put(key string, palette []ColAndFreq) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.addLocked(key, copyColAndFreqs(palette))
+	if c.dir != "" {
+		data, err := json.Marshal(palette)
+		if err == nil && ioutil.WriteFile(c.diskPath(key), data, 0644) == nil {
+			c.pruneDiskLocked()
+		}
+	}
+}
+
+func (c *PaletteCache) addLocked(key string, palette []ColAndFreq) {
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheEntry).palette = palette
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key, palette})
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Removes one entry from memory and disk.
+func (c *PaletteCache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+	}
+	if c.dir != "" {
+		os.Remove(c.diskPath(key))
+	}
+}
+
+// Removes every entry from memory and disk and resets the statistics.
+func (c *PaletteCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.stats = CacheStats{}
+	if c.dir == "" {
+		return nil
+	}
+	files, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.Remove(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *PaletteCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+func (c *PaletteCache) diskPath(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Deletes the least recently written files until the store fits in
+// maxDiskBytes.
+func (c *PaletteCache) pruneDiskLocked() {
+	if c.maxDiskBytes <= 0 {
+		return
+	}
+	files, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.Size()
+	}
+	if total <= c.maxDiskBytes {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, f := range files {
+		if total <= c.maxDiskBytes {
+			break
+		}
+		if filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		if os.Remove(filepath.Join(c.dir, f.Name())) == nil {
+			total -= f.Size()
+		}
+	}
+}
+
+// Extract through the cache. hit reports whether the result was cached.
+func (c *PaletteCache) Extract(
+	name string,
+	img image.Image,
+	opts Options,
+) (palette []ColAndFreq, hit bool, err error) {
+	key := CacheKey(ImageHash(img), name, opts)
+	if palette, ok := c.Get(key); ok {
+		return palette, true, nil
+	}
+
+	palette, err = Extract(name, img, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	c.Put(key, palette)
+	return palette, false, nil
+}
+
+func copyColAndFreqs(palette []ColAndFreq) []ColAndFreq {
+	ret := make([]ColAndFreq, len(palette))
+	copy(ret, palette)
+	return ret
+}
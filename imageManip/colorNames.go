@@ -0,0 +1,121 @@
+package imageManip
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/colornames"
+)
+
+// Dictionaries NearestColorName can search. "all" is both, CSS names win
+// when both have the same color.
+const (
+	COLOR_NAMES_CSS  = "css"
+	COLOR_NAMES_XKCD = "xkcd"
+	COLOR_NAMES_ALL  = "all"
+)
+
+var COLOR_NAME_DICTIONARIES = []string{COLOR_NAMES_ALL, COLOR_NAMES_CSS, COLOR_NAMES_XKCD}
+
+// Names within this OKLab ΔE are a match, further away they're
+// "name-ish".
+const COLOR_NAME_CLOSE = 0.02
+
+type namedColor struct {
+	name    string
+	r, g, b uint8
+}
+
+// A color's nearest named color.
+type ColorName struct {
+	Name       string `json:"name"`
+	Dictionary string `json:"dictionary"`
+	// The named color itself and its OKLab distance from the color.
+	Hex    string  `json:"hex"`
+	DeltaE float64 `json:"deltaE"`
+}
+
+// Name, with "-ish" added when the color is not close to it. Names like
+// "reddish" already say that.
+func (n ColorName) Label() string {
+	if n.DeltaE <= COLOR_NAME_CLOSE || strings.HasSuffix(n.Name, "ish") {
+		return n.Name
+	}
+	return n.Name + "-ish"
+}
+
+type colorDictionary struct {
+	name   string
+	colors []namedColor
+	labs   []OKLab
+}
+
+var (
+	dictionariesOnce sync.Once
+	dictionaries     map[string][]colorDictionary
+)
+
+func loadDictionaries() {
+	css := make([]namedColor, len(colornames.Names))
+	for i, name := range colornames.Names {
+		c := colornames.Map[name]
+		css[i] = namedColor{name, c.R, c.G, c.B}
+	}
+	cssDict := newColorDictionary(COLOR_NAMES_CSS, css)
+	xkcdDict := newColorDictionary(COLOR_NAMES_XKCD, xkcdColorNames)
+	dictionaries = map[string][]colorDictionary{
+		COLOR_NAMES_CSS:  {cssDict},
+		COLOR_NAMES_XKCD: {xkcdDict},
+		COLOR_NAMES_ALL:  {cssDict, xkcdDict},
+	}
+}
+
+func newColorDictionary(name string, colors []namedColor) colorDictionary {
+	d := colorDictionary{name: name, colors: colors, labs: make([]OKLab, len(colors))}
+	for i, c := range colors {
+		d.labs[i] = NRGBAToOKLab(color.NRGBA{c.r, c.g, c.b, 0xff})
+	}
+	return d
+}
+
+// Nearest name to c by OKLab distance in the dictionary (one of
+// COLOR_NAME_DICTIONARIES).
+func NearestColorName(c color.NRGBA, dictionary string) (ColorName, error) {
+	dictionariesOnce.Do(loadDictionaries)
+	dicts, ok := dictionaries[dictionary]
+	if !ok {
+		return ColorName{}, fmt.Errorf("Unknown color name dictionary %q. Available: %v.", dictionary, COLOR_NAME_DICTIONARIES)
+	}
+
+	lab := NRGBAToOKLab(c)
+	var best ColorName
+	bestDist := math.Inf(1)
+	for _, d := range dicts {
+		for i, other := range d.labs {
+			// Strictly closer, so earlier dictionaries win ties.
+			if dist := lab.distance(other); dist < bestDist {
+				bestDist = dist
+				nc := d.colors[i]
+				best = ColorName{
+					Name:       nc.name,
+					Dictionary: d.name,
+					Hex:        NRGBAToHex(color.NRGBA{nc.r, nc.g, nc.b, 0xff}),
+				}
+			}
+		}
+	}
+	best.DeltaE = math.Round(bestDist*1000) / 1000
+	return best, nil
+}
+
+// Nearest name of every palette color, from all dictionaries.
+func NamePalette(palette []ColAndFreq) []ColorName {
+	names := make([]ColorName, len(palette))
+	for i, c := range palette {
+		names[i], _ = NearestColorName(HexToNRGBA(c.ColString), COLOR_NAMES_ALL)
+	}
+	return names
+}
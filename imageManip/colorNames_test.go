@@ -0,0 +1,36 @@
+package imageManip
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestNearestColorName(t *testing.T) {
+	tests := []struct {
+		c          color.NRGBA
+		dictionary string
+		name, dict string
+		label      string
+	}{
+		{color.NRGBA{0xff, 0x00, 0x00, 0xff}, COLOR_NAMES_ALL, "red", COLOR_NAMES_CSS, "red"},
+		{color.NRGBA{0xe5, 0x00, 0x00, 0xff}, COLOR_NAMES_ALL, "xkcd red", COLOR_NAMES_XKCD, "xkcd red"},
+		{color.NRGBA{0xac, 0xc2, 0xd9, 0xff}, COLOR_NAMES_ALL, "cloudy blue", COLOR_NAMES_XKCD, "cloudy blue"},
+		{color.NRGBA{0xe5, 0x00, 0x00, 0xff}, COLOR_NAMES_CSS, "crimson", COLOR_NAMES_CSS, "crimson-ish"},
+		{color.NRGBA{0x00, 0x00, 0x00, 0xff}, COLOR_NAMES_XKCD, "black", COLOR_NAMES_XKCD, "black"},
+	}
+	for _, tt := range tests {
+		got, err := NearestColorName(tt.c, tt.dictionary)
+		if err != nil {
+			t.Errorf("%v in %s: %v", tt.c, tt.dictionary, err)
+			continue
+		}
+		if got.Name != tt.name || got.Dictionary != tt.dict || got.Label() != tt.label {
+			t.Errorf("%v in %s: got %s (%s, %s), want %s (%s, %s)",
+				tt.c, tt.dictionary, got.Name, got.Dictionary, got.Label(), tt.name, tt.dict, tt.label)
+		}
+	}
+
+	if _, err := NearestColorName(color.NRGBA{}, "no-such-dictionary"); err == nil {
+		t.Error("no error for an unknown dictionary")
+	}
+}
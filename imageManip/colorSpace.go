@@ -0,0 +1,251 @@
+package imageManip
+
+import (
+	"image/color"
+	"math"
+)
+
+// Conversions between sRGB and the spaces used for editing and comparing
+// colors. HSL is what most people expect from a color picker, OKLab/OKLCH
+// is the perceptual space (Björn Ottosson, 2020) used for distances.
+
+type OKLab struct {
+	L float64
+	A float64
+	B float64
+}
+
+// L in [0, 1], C roughly in [0, 0.37] for sRGB, H in degrees.
+type OKLCH struct {
+	L float64
+	C float64
+	H float64
+}
+
+// convert a color.NRGBA to a hex string in format "#ffffff"
+func NRGBAToHex(c color.NRGBA) string {
+	return rgbPixelToHexString([]int{int(c.R), int(c.G), int(c.B)})
+}
+
+// h in degrees, s and l in [0, 1].
+func NRGBAToHSL(c color.NRGBA) (h, s, l float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	maxC := math.Max(r, math.Max(g, b))
+	minC := math.Min(r, math.Min(g, b))
+	l = (maxC + minC) / 2
+
+	delta := maxC - minC
+	if delta == 0 {
+		return 0, 0, l
+	}
+
+	if l > 0.5 {
+		s = delta / (2 - maxC - minC)
+	} else {
+		s = delta / (maxC + minC)
+	}
+
+	switch maxC {
+	case r:
+		h = (g - b) / delta
+		if g < b {
+			h += 6
+		}
+	case g:
+		h = (b-r)/delta + 2
+	default:
+		h = (r-g)/delta + 4
+	}
+	h *= 60
+	return
+}
+
+func HSLToNRGBA(h, s, l float64) color.NRGBA {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	s = clamp01(s)
+	l = clamp01(l)
+
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return color.NRGBA{
+		R: to8Bit(r + m),
+		G: to8Bit(g + m),
+		B: to8Bit(b + m),
+		A: 0xff,
+	}
+}
+
+func NRGBAToOKLab(c color.NRGBA) OKLab {
+	return linearToOKLab(
+		srgbToLinear(float64(c.R)/255),
+		srgbToLinear(float64(c.G)/255),
+		srgbToLinear(float64(c.B)/255),
+	)
+}
+
+// Out of gamut values are clipped per channel.
+func OKLabToNRGBA(lab OKLab) color.NRGBA {
+	r, g, b := okLabToLinear(lab)
+	return color.NRGBA{
+		R: to8Bit(linearToSrgb(clamp01(r))),
+		G: to8Bit(linearToSrgb(clamp01(g))),
+		B: to8Bit(linearToSrgb(clamp01(b))),
+		A: 0xff,
+	}
+}
+
+func (lab OKLab) LCH() OKLCH {
+	h := math.Atan2(lab.B, lab.A) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return OKLCH{
+		L: lab.L,
+		C: math.Hypot(lab.A, lab.B),
+		H: h,
+	}
+}
+
+func (lch OKLCH) Lab() OKLab {
+	rad := lch.H * math.Pi / 180
+	return OKLab{
+		L: lch.L,
+		A: lch.C * math.Cos(rad),
+		B: lch.C * math.Sin(rad),
+	}
+}
+
+func NRGBAToOKLCH(c color.NRGBA) OKLCH {
+	return NRGBAToOKLab(c).LCH()
+}
+
+// Keeps lightness and hue, and lowers chroma until the color fits in sRGB.
+func OKLCHToNRGBA(lch OKLCH) color.NRGBA {
+	lch.L = clamp01(lch.L)
+	if lch.C < 0 {
+		lch.C = 0
+	}
+	if inGamut(lch.Lab()) {
+		return OKLabToNRGBA(lch.Lab())
+	}
+
+	// Binary search on chroma.
+	lo, hi := 0.0, lch.C
+	for i := 0; i < 24; i++ {
+		mid := (lo + hi) / 2
+		if inGamut(OKLCH{lch.L, mid, lch.H}.Lab()) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	lch.C = lo
+	return OKLabToNRGBA(lch.Lab())
+}
+
+// Euclidean distance in OKLab. A just noticeable difference is about 0.02.
+func DeltaEOK(c1, c2 color.NRGBA) float64 {
+	return NRGBAToOKLab(c1).distance(NRGBAToOKLab(c2))
+}
+
+func (lab OKLab) distance(other OKLab) float64 {
+	return math.Sqrt(sq(lab.L-other.L) + sq(lab.A-other.A) + sq(lab.B-other.B))
+}
+
+func inGamut(lab OKLab) bool {
+	const eps = 1e-4
+	r, g, b := okLabToLinear(lab)
+	return r >= -eps && r <= 1+eps &&
+		g >= -eps && g <= 1+eps &&
+		b >= -eps && b <= 1+eps
+}
+
+func linearToOKLab(r, g, b float64) OKLab {
+	l := 0.4122214708*r + 0.5363325363*g + 0.0514459929*b
+	m := 0.2119034982*r + 0.6806995451*g + 0.1073969566*b
+	s := 0.0883024619*r + 0.2817188376*g + 0.6299787005*b
+
+	l, m, s = math.Cbrt(l), math.Cbrt(m), math.Cbrt(s)
+
+	return OKLab{
+		L: 0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		A: 1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		B: 0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+func okLabToLinear(lab OKLab) (r, g, b float64) {
+	l := lab.L + 0.3963377774*lab.A + 0.2158037573*lab.B
+	m := lab.L - 0.1055613458*lab.A - 0.0638541728*lab.B
+	s := lab.L - 0.0894841775*lab.A - 1.2914855480*lab.B
+
+	l, m, s = l*l*l, m*m*m, s*s*s
+
+	r = 4.0767416621*l - 3.3077115913*m + 0.2309699292*s
+	g = -1.2684380046*l + 2.6097574011*m - 0.3413193965*s
+	b = -0.0041960863*l - 0.7034186147*m + 1.7076147010*s
+	return
+}
+
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSrgb(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// [0, 1] float to a rounded 8-bit channel value.
+func to8Bit(v float64) uint8 {
+	return uint8(math.Round(clamp01(v) * 255))
+}
+
+// Interpolates between a and b in OKLCH, going the short way around the
+// hue circle. A grey takes the hue of the other color so mixing with it
+// doesn't swing through unrelated hues.
+func MixOKLCH(a, b OKLCH, t float64) OKLCH {
+	const greyChroma = 0.002
+	if a.C < greyChroma {
+		a.H = b.H
+	}
+	if b.C < greyChroma {
+		b.H = a.H
+	}
+	dh := math.Mod(b.H-a.H+540, 360) - 180
+	h := math.Mod(a.H+dh*t+360, 360)
+	return OKLCH{
+		L: a.L + (b.L-a.L)*t,
+		C: a.C + (b.C-a.C)*t,
+		H: h,
+	}
+}
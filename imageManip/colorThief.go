@@ -8,7 +8,6 @@ import (
 	"math"
 	"errors"
 	"strconv"
-	"fmt"
 )
 
 // I am re-implementing the MMCQ (modified median cut quantization) algorithm
@@ -46,22 +45,26 @@ func getColorIndex(r int, g int, b int) int {
 // histo is a map that gives the number of pixels in each quantized region
 // of color space.
 func getHisto(pixels [][]int) map[int]int {
-	fmt.Println("getHisto called.")
+	debugln("getHisto called.")
 	histo := make(map[int]int)
 	for _, pixel := range(pixels) {
-		// 8-bit values turned into 5-bit values.
-		rval := pixel[0] >> RSHIFT // This is the same as integer division by 8.
-		gval := pixel[1] >> RSHIFT
-		bval := pixel[2] >> RSHIFT
-		index := getColorIndex(rval, gval, bval)
-		histo[index] += 1
+		addToHisto(histo, pixel[0], pixel[1], pixel[2])
 	}
-	fmt.Println("getHisto returning.")
+	debugln("getHisto returning.")
 	return histo
 }
 
+// Counts one 8-bit rgb pixel in histo.
+func addToHisto(histo map[int]int, r, g, b int) {
+	// 8-bit values turned into 5-bit values.
+	rval := r >> RSHIFT // This is the same as integer division by 8.
+	gval := g >> RSHIFT
+	bval := b >> RSHIFT
+	histo[getColorIndex(rval, gval, bval)] += 1
+}
+
 func vBoxFromPixels(pixels [][]int, histo map[int]int) *VBox {
-	fmt.Println("vBoxFromPixels called.")
+	debugln("vBoxFromPixels called.")
 	rmin := 1000000
 	rmax := 0
 	gmin := 1000000
@@ -81,7 +84,7 @@ func vBoxFromPixels(pixels [][]int, histo map[int]int) *VBox {
 		bmax = max(bval, bmax)
 	}
 
-	fmt.Println("vBoxFromPixels returning.")
+	debugln("vBoxFromPixels returning.")
 	return &VBox{
 		r1: rmin,
 		r2: rmax,
@@ -95,15 +98,15 @@ func vBoxFromPixels(pixels [][]int, histo map[int]int) *VBox {
 
 // This function decides how to split each vbox.
 func medianCutApply(histo map[int]int, vbox VBox) (VBox, VBox) {
-	fmt.Println("medianCutApply called.")
+	debugln("medianCutApply called.")
 	// Return nothing if vbox contains no pixels.
 	if (vbox.count() == 0) {
-		fmt.Println("medianCutApply returning zero size.")
+		debugln("medianCutApply returning zero size.")
 		return VBox{invalid: true}, VBox{invalid: true}
 	}
 	// If only one pixel just return original vbox without splitting.
 	if (vbox.count() == 1) {
-		fmt.Println("medianCutApply returning one pixel.")
+		debugln("medianCutApply returning one pixel.")
 		return vbox.copy(), VBox{invalid: true}
 	}
 
@@ -138,7 +141,7 @@ func medianCutApply(histo map[int]int, vbox VBox) (VBox, VBox) {
 			sum = 0
 			for j := vbox.r1; j <= vbox.r2; j++ {
 				for k := vbox.b1; k <= vbox.b2; k++ {
-					index := getColorIndex(i, j, k)
+					index := getColorIndex(j, i, k)
 					sum += histo[index]
 				}
 			}
@@ -151,7 +154,7 @@ func medianCutApply(histo map[int]int, vbox VBox) (VBox, VBox) {
 			sum = 0
 			for j := vbox.r1; j <= vbox.r2; j++ {
 				for k := vbox.g1; k <= vbox.g2; k++ {
-					index := getColorIndex(i, j, k)
+					index := getColorIndex(j, k, i)
 					sum += histo[index]
 				}
 			}
@@ -189,7 +192,7 @@ func medianCutApply(histo map[int]int, vbox VBox) (VBox, VBox) {
 			if left <= right {
 				d2 = min(dim2Val - 1, i + right/2)
 			} else {
-				d2 = max(dim2Val, i - 1 - left/2)
+				d2 = max(dim1Val, i - 1 - left/2)
 			}
 			// Avoid 0-count boxes.
 			for partialSum[d2] == 0 {
@@ -205,10 +208,10 @@ func medianCutApply(histo map[int]int, vbox VBox) (VBox, VBox) {
 			// Create function to set attributes based on strings.
 			vbox1.setDimWithString(dim2, d2)
 			vbox2.setDimWithString(dim1, vbox1.getDimWithString(dim2)+1)
-			fmt.Println("medianCutApply returning.")
-			fmt.Println("vbox1:")
+			debugln("medianCutApply returning.")
+			debugln("vbox1:")
 			vbox1.printBounds()
-			fmt.Println("vbox2:")
+			debugln("vbox2:")
 			vbox2.printBounds()
 			return vbox1, vbox2
 		}
@@ -218,7 +221,7 @@ func medianCutApply(histo map[int]int, vbox VBox) (VBox, VBox) {
 
 // maxColor is the max number of colors to extract.
 func quantize(pixels [][]int, maxColor int) (CMap, error) {
-	fmt.Println("quantize has been called.")
+	debugln("quantize has been called.")
 	if len(pixels) == 0 {
 		retErr := errors.New("In Quantize: Empty pixel array.\n")
 		return CMap{invalid: true}, retErr
@@ -230,34 +233,42 @@ func quantize(pixels [][]int, maxColor int) (CMap, error) {
 
 	histo := getHisto(pixels)
 	if len(histo) <= maxColor {
-		retErr := errors.New("In Quantize: len(histo) <= maxColor.")
-		return CMap{invalid: true}, retErr
+		// Nothing to cut, every occupied region is a color.
+		cmap := *createCMap()
+		mask := 1<<SIGBITS - 1
+		for index := range histo {
+			r := index >> (2 * SIGBITS)
+			g := index >> SIGBITS & mask
+			b := index & mask
+			cmap.push(VBox{r1: r, r2: r, g1: g, g2: g, b1: b, b2: b, histo: histo})
+		}
+		return cmap, nil
 	}
 
 	// Get the starting vbox from the colors.
 	vbox := *vBoxFromPixels(pixels, histo)
 	//fmt.Printf("vbox:\n%+v\n", vbox)
-	fmt.Println("Creating VQueue.")
+	debugln("Creating VQueue.")
 	vq := *createVQueue("byCount")
 	vq.push(vbox)
-	fmt.Println("VQueue created and vbox pushed into contents.")
+	debugln("VQueue created and vbox pushed into contents.")
 	//fmt.Printf("vq:\n%+v\n", vq)
 
 	// Inner function to do the iteration.
-	iter := func(lh VQueue, target float64) error {
+	iter := func(lh *VQueue, target float64) error {
 		nColor := 1
 		nIter := 0
 		for nIter < MAX_ITERATION {
-			fmt.Printf("nIter: %d, nColor: %d\n", nIter, nColor)
+			debugf("nIter: %d, nColor: %d\n", nIter, nColor)
 			vbox_ := lh.pop()
-			fmt.Println("vbox_ created.")
+			debugln("vbox_ created.")
 			if vbox_.count() == 0 {
-				fmt.Println("vbox_.count() == 0")
+				debugln("vbox_.count() == 0")
 				lh.push(vbox_)
 				nIter += 1
 				continue
 			}
-			fmt.Println("vbox_.count() != 0")
+			debugln("vbox_.count() != 0")
 			// Do the cut.
 			vbox1, vbox2 := medianCutApply(histo, vbox_)
 			if vbox1.invalid {
@@ -279,28 +290,28 @@ func quantize(pixels [][]int, maxColor int) (CMap, error) {
 		}
 		return errors.New("In Quantize:iter: No proper return.")
 	}
-	fmt.Println("iter function created.")
+	debugln("iter function created.")
 
 	// First set of colors, sorted by population.
-	fmt.Println("First iter called.")
-	err := iter(vq, FRACT_BY_POPULATIONS * float64(maxColor))
-	fmt.Println("First iter returned.")
+	debugln("First iter called.")
+	err := iter(&vq, FRACT_BY_POPULATIONS * float64(maxColor))
+	debugln("First iter returned.")
 	if err != nil {
 		return CMap{invalid: true}, err
 	}
 
 	// Re-sort by the product of pixel occupancy times the size in a color 
 	// space.
-	fmt.Println("Creating second VQueue.")
+	debugln("Creating second VQueue.")
 	vq2 := *createVQueue("byCountTimesVolume")
-	fmt.Println("Second VQueue created.")
+	debugln("Second VQueue created.")
 	for vq.size() > 0 {
 		vq2.push(vq.pop())
 	}
-	fmt.Println("VBoxes pushed onto second VQueue.")
+	debugln("VBoxes pushed onto second VQueue.")
 
 	// Next set: Generate the median cuts using the (npix * vol) sorting.
-	err = iter(vq2, float64(maxColor - vq2.size()))
+	err = iter(&vq2, float64(maxColor - vq2.size()))
 	if err != nil {
 		return CMap{invalid: true}, err
 	}
@@ -310,7 +321,7 @@ func quantize(pixels [][]int, maxColor int) (CMap, error) {
 	for vq2.size() > 0 {
 		cmap.push(vq2.pop())
 	}
-	fmt.Println("quantize returning.")
+	debugln("quantize returning.")
 	return cmap, nil
 }
 
@@ -336,16 +347,19 @@ func GetPalette(img image.Image, colorCount int) ([]ColAndFreq, error) {
 		return nil, err
 	}
 	retFirst := rgbPixelsToHexStrings(cMap.palette())
-	retFinal := stringsToColAndFreqs(retFirst)
+	retFinal := stringsToColAndFreqs(retFirst, cMap.counts())
 	return retFinal, nil
 }
 
-// Frequency field carries no info.
+// Pairs each color with the number of pixels in its vbox. Colors of empty
+// vboxes aren't in the image and are left out.
 // Band-aid function to fit original API.
-func stringsToColAndFreqs(strs []string) []ColAndFreq {
-	retSlice := make([]ColAndFreq, len(strs))
+func stringsToColAndFreqs(strs []string, counts []int) []ColAndFreq {
+	retSlice := make([]ColAndFreq, 0, len(strs))
 	for i, str := range strs {
-		retSlice[i] = ColAndFreq{ColString: str, Frequency: 0}
+		if counts[i] > 0 {
+			retSlice = append(retSlice, ColAndFreq{ColString: str, Frequency: counts[i]})
+		}
 	}
 	return retSlice
 }
@@ -434,11 +448,8 @@ func (v *VBox) volume() int {
 	return (sub_r + 1) * (sub_g + 1) * (sub_b + 1)
 }
 
+// The histogram is never modified, copies share it like in colorthief.
 func (v *VBox) copy() VBox {
-	histo := make(map[int]int)
-	for k, v := range(v.histo) {
-		histo[k] = histo[v]
-	}
 	return VBox{
 		r1: v.r1,
 		r2: v.r2,
@@ -446,7 +457,7 @@ func (v *VBox) copy() VBox {
 		g2: v.g2,
 		b1: v.b1,
 		b2: v.b2,
-		histo: histo,
+		histo: v.histo,
 		invalid: false,
 	}
 }
@@ -513,7 +524,7 @@ func (v *VBox) contains(pixel []int) bool {
 }
 
 func (v *VBox) count() int {
-	fmt.Println("count method called.")
+	debugln("count method called.")
 	v.printBounds()
 	npix := 0
 	for i := v.r1; i <= v.r2; i++ {
@@ -524,17 +535,17 @@ func (v *VBox) count() int {
 			}
 		}
 	}
-	fmt.Printf("count method returning %d.\n", npix)
+	debugf("count method returning %d.\n", npix)
 	return npix
 }
 
 func (v *VBox) printBounds() {
-	fmt.Printf("r1: %d\n", v.r1)
-	fmt.Printf("r2: %d\n", v.r2)
-	fmt.Printf("g1: %d\n", v.g1)
-	fmt.Printf("g2: %d\n", v.g2)
-	fmt.Printf("b1: %d\n", v.b1)
-	fmt.Printf("b2: %d\n", v.b2)
+	debugf("r1: %d\n", v.r1)
+	debugf("r2: %d\n", v.r2)
+	debugf("g1: %d\n", v.g1)
+	debugf("g2: %d\n", v.g2)
+	debugf("b1: %d\n", v.b1)
+	debugf("b2: %d\n", v.b2)
 }
 //------------------------------------------------------------------------------
 type vbAndColor struct {
@@ -564,6 +575,16 @@ func (c CMap) palette() [][]int {
 	return ret
 }
 
+// Returns the number of pixels in each vbox, in the same order as palette.
+func (c CMap) counts() []int {
+	ret := make([]int, c.vBoxes.size())
+	for i := range(ret) {
+		vbox := c.vBoxes.peek(i).vbox
+		ret[i] = vbox.count()
+	}
+	return ret
+}
+
 func (c *CMap) push(vbox VBox) {
 	newVbc := vbAndColor{
 		vbox: vbox,
@@ -577,23 +598,29 @@ func (c CMap) size() int {
 }
 
 func (c CMap) nearest(color []int) []int {
-	var d1 float64 = 42.0000000042
-	p_color := make([]int, 3)
+	palette := c.palette()
+	i := nearestColorIndex(palette, color)
+	if i < 0 {
+		return make([]int, 3)
+	}
+	return palette[i]
+}
 
-	for i := 0; i < c.vBoxes.size(); i++ {
-		vbox := c.vBoxes.peek(i)
+// Index of the color of palette closest to color in RGB, -1 if palette is
+// empty.
+func nearestColorIndex(palette [][]int, color []int) int {
+	nearest, d1 := -1, 0.0
+	for i, p := range palette {
 		d2 := math.Sqrt(
-			math.Pow(float64(color[0] - vbox.color[0]), 2) +
-			math.Pow(float64(color[1] - vbox.color[1]), 2) +
-			math.Pow(float64(color[2] - vbox.color[2]), 2),
+			math.Pow(float64(color[0]-p[0]), 2) +
+				math.Pow(float64(color[1]-p[1]), 2) +
+				math.Pow(float64(color[2]-p[2]), 2),
 		)
-		if d1 == 42.0000000042 || d2 < d1 {
-			tempC := vbox.color
-			d1 = d2
-			p_color = []int{tempC[0], tempC[1], tempC[2]}
+		if nearest < 0 || d2 < d1 {
+			nearest, d1 = i, d2
 		}
 	}
-	return p_color
+	return nearest
 }
 
 /*
@@ -669,7 +696,7 @@ func (vq *VQueue) pop() VBox {
 		vq.sort()
 	}
 	ret := vq.contents[len(vq.contents) - 1]
-	vq.contents = vq.contents[:len(vq.contents)]
+	vq.contents = vq.contents[:len(vq.contents) - 1]
 	return ret
 }
 
@@ -744,7 +771,7 @@ func (vcq *VCQueue) pop() vbAndColor {
 		vcq.sort()
 	}
 	ret := vcq.contents[len(vcq.contents) - 1]
-	vcq.contents = vcq.contents[:len(vcq.contents)]
+	vcq.contents = vcq.contents[:len(vcq.contents) - 1]
 	return ret
 }
 
@@ -0,0 +1,211 @@
+package imageManip
+
+import (
+	"image/color"
+	"math"
+)
+
+// WCAG 2.x relative luminance of an sRGB color, 0 for black, 1 for white.
+func RelativeLuminance(c color.NRGBA) float64 {
+	r := srgbToLinear(float64(c.R) / 255)
+	g := srgbToLinear(float64(c.G) / 255)
+	b := srgbToLinear(float64(c.B) / 255)
+	return 0.2126*r + 0.7152*g + 0.0722*b
+}
+
+// WCAG 2.x contrast ratio, from 1 (same color) to 21 (black on white).
+func ContrastRatio(c1, c2 color.NRGBA) float64 {
+	l1, l2 := RelativeLuminance(c1), RelativeLuminance(c2)
+	if l1 < l2 {
+		l1, l2 = l2, l1
+	}
+	return (l1 + 0.05) / (l2 + 0.05)
+}
+
+// Moves the OKLCH lightness of c away from bg until the contrast ratio is
+// at least minRatio. Hue is kept, chroma only shrinks if the lighter or
+// darker color doesn't fit in sRGB. Returns white or black if even those
+// don't reach minRatio.
+func EnsureContrast(c, bg color.NRGBA, minRatio float64) color.NRGBA {
+	if ContrastRatio(c, bg) >= minRatio {
+		return c
+	}
+
+	lch := NRGBAToOKLCH(c)
+	// Go lighter on dark backgrounds and darker on light ones.
+	step := 0.01
+	if RelativeLuminance(bg) > 0.18 {
+		step = -0.01
+	}
+	for l := lch.L; l >= 0 && l <= 1; l += step {
+		candidate := OKLCHToNRGBA(OKLCH{L: l, C: lch.C, H: lch.H})
+		if ContrastRatio(candidate, bg) >= minRatio {
+			return candidate
+		}
+	}
+
+	if step > 0 {
+		return color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	}
+	return color.NRGBA{A: 255}
+}
+
+// Smallest angle between two hues in degrees.
+func hueDistance(h1, h2 float64) float64 {
+	d := math.Mod(math.Abs(h1-h2), 360)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// WCAG 2.x minimum ratios. Large text is 18pt, or 14pt bold.
+const (
+	WCAG_AA_NORMAL  = 4.5
+	WCAG_AA_LARGE   = 3
+	WCAG_AAA_NORMAL = 7
+	WCAG_AAA_LARGE  = 4.5
+)
+
+// APCA Lc a palette color needs to be picked as text color over black or
+// white. 60 is APCA's minimum for body text.
+const APCA_BODY_TEXT_LC = 60
+
+// APCA (0.0.98G) lightness contrast of text on bg, roughly -108 to 106.
+// Positive for dark text on a light background, negative the other way.
+func APCAContrast(text, bg color.NRGBA) float64 {
+	const (
+		blkThrs   = 0.022
+		blkClmp   = 1.414
+		deltaYMin = 0.0005
+		scale     = 1.14
+		loOffset  = 0.027
+		loClip    = 0.1
+		normBG    = 0.56
+		normTXT   = 0.57
+		revBG     = 0.65
+		revTXT    = 0.62
+	)
+	yText, yBG := apcaLuminance(text), apcaLuminance(bg)
+	if yText < blkThrs {
+		yText += math.Pow(blkThrs-yText, blkClmp)
+	}
+	if yBG < blkThrs {
+		yBG += math.Pow(blkThrs-yBG, blkClmp)
+	}
+	if math.Abs(yBG-yText) < deltaYMin {
+		return 0
+	}
+
+	if yBG > yText {
+		sapc := (math.Pow(yBG, normBG) - math.Pow(yText, normTXT)) * scale
+		if sapc < loClip {
+			return 0
+		}
+		return (sapc - loOffset) * 100
+	}
+	sapc := (math.Pow(yBG, revBG) - math.Pow(yText, revTXT)) * scale
+	if sapc > -loClip {
+		return 0
+	}
+	return (sapc + loOffset) * 100
+}
+
+// APCA uses a plain 2.4 gamma rather than the sRGB curve.
+func apcaLuminance(c color.NRGBA) float64 {
+	return 0.2126729*math.Pow(float64(c.R)/255, 2.4) +
+		0.7151522*math.Pow(float64(c.G)/255, 2.4) +
+		0.0721750*math.Pow(float64(c.B)/255, 2.4)
+}
+
+// Contrast of one foreground on one background.
+type ContrastPair struct {
+	Foreground string  `json:"foreground"`
+	Background string  `json:"background"`
+	Ratio      float64 `json:"ratio"`
+	APCA       float64 `json:"apca"`
+	AANormal   bool    `json:"aaNormal"`
+	AALarge    bool    `json:"aaLarge"`
+	AAANormal  bool    `json:"aaaNormal"`
+	AAALarge   bool    `json:"aaaLarge"`
+}
+
+func NewContrastPair(fg, bg color.NRGBA) ContrastPair {
+	ratio := ContrastRatio(fg, bg)
+	return ContrastPair{
+		Foreground: NRGBAToHex(fg),
+		Background: NRGBAToHex(bg),
+		Ratio:      math.Round(ratio*100) / 100,
+		APCA:       math.Round(APCAContrast(fg, bg)*10) / 10,
+		AANormal:   ratio >= WCAG_AA_NORMAL,
+		AALarge:    ratio >= WCAG_AA_LARGE,
+		AAANormal:  ratio >= WCAG_AAA_NORMAL,
+		AAALarge:   ratio >= WCAG_AAA_LARGE,
+	}
+}
+
+// Contrast of every color against every other one.
+type ContrastMatrix struct {
+	// The palette followed by black and white.
+	Colors []string `json:"colors"`
+	// Pairs[i][j] is Colors[j] as text on Colors[i].
+	Pairs [][]ContrastPair `json:"pairs"`
+	// Text color to use on each of Colors, see BestTextColor.
+	BestText []string `json:"bestText"`
+}
+
+// Matrix of palette plus black and white (unless the palette has them).
+func NewContrastMatrix(palette []ColAndFreq) ContrastMatrix {
+	cols := make([]color.NRGBA, 0, len(palette)+2)
+	seen := make(map[color.NRGBA]bool)
+	for _, c := range palette {
+		col := HexToNRGBA(c.ColString)
+		if !seen[col] {
+			seen[col] = true
+			cols = append(cols, col)
+		}
+	}
+	for _, col := range []color.NRGBA{{A: 255}, {R: 255, G: 255, B: 255, A: 255}} {
+		if !seen[col] {
+			cols = append(cols, col)
+		}
+	}
+
+	m := ContrastMatrix{
+		Colors:   make([]string, len(cols)),
+		Pairs:    make([][]ContrastPair, len(cols)),
+		BestText: make([]string, len(cols)),
+	}
+	for i, bg := range cols {
+		m.Colors[i] = NRGBAToHex(bg)
+		m.Pairs[i] = make([]ContrastPair, len(cols))
+		for j, fg := range cols {
+			m.Pairs[i][j] = NewContrastPair(fg, bg)
+		}
+		m.BestText[i] = NRGBAToHex(BestTextColor(bg, cols))
+	}
+	return m
+}
+
+// Picks the text color for bg. The candidate with the strongest APCA
+// contrast wins if it reaches APCA_BODY_TEXT_LC, so text can stay on
+// palette; otherwise it's whichever of black and white reads better.
+func BestTextColor(bg color.NRGBA, candidates []color.NRGBA) color.NRGBA {
+	black, white := color.NRGBA{A: 255}, color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	best, bestLc := black, -1.0
+	for _, c := range candidates {
+		if c == black || c == white {
+			continue
+		}
+		if lc := math.Abs(APCAContrast(c, bg)); lc > bestLc {
+			best, bestLc = c, lc
+		}
+	}
+	if bestLc >= APCA_BODY_TEXT_LC {
+		return best
+	}
+	if math.Abs(APCAContrast(white, bg)) > math.Abs(APCAContrast(black, bg)) {
+		return white
+	}
+	return black
+}
@@ -0,0 +1,47 @@
+package imageManip
+
+import (
+	"math"
+	"testing"
+)
+
+// Pairs from the examples of the APCA reference implementation (apca-w3,
+// 0.0.98G-4g constants).
+func TestAPCAContrast(t *testing.T) {
+	tests := []struct {
+		text, bg string
+		want     float64
+	}{
+		{"#888888", "#ffffff", 63.056469930209424},
+		{"#ffffff", "#888888", -68.54146436644962},
+		{"#000000", "#aaaaaa", 58.146262578561334},
+		{"#aaaaaa", "#000000", -56.24113336839742},
+		{"#ffffff", "#ffffff", 0},
+	}
+	for _, tt := range tests {
+		got := APCAContrast(HexToNRGBA(tt.text), HexToNRGBA(tt.bg))
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s on %s: got %v, want %v", tt.text, tt.bg, got, tt.want)
+		}
+	}
+}
+
+// WCAG 2.x ratios, #767676 is the lightest gray passing AA on white.
+func TestContrastRatio(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"#000000", "#ffffff", 21},
+		{"#ffffff", "#000000", 21},
+		{"#767676", "#ffffff", 4.54},
+		{"#777777", "#ffffff", 4.48},
+		{"#123456", "#123456", 1},
+	}
+	for _, tt := range tests {
+		got := ContrastRatio(HexToNRGBA(tt.a), HexToNRGBA(tt.b))
+		if math.Abs(got-tt.want) > 0.005 {
+			t.Errorf("%s and %s: got %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
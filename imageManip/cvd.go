@@ -0,0 +1,193 @@
+package imageManip
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// Color vision deficiencies SimulateCVD can show.
+const (
+	CVD_PROTANOPIA    = "protanopia"
+	CVD_DEUTERANOPIA  = "deuteranopia"
+	CVD_TRITANOPIA    = "tritanopia"
+	CVD_ACHROMATOPSIA = "achromatopsia"
+)
+
+var CVD_TYPES = []string{CVD_PROTANOPIA, CVD_DEUTERANOPIA, CVD_TRITANOPIA, CVD_ACHROMATOPSIA}
+
+// Palette colors closer than this (OKLab ΔE) under a deficiency are
+// reported as a conflict.
+const CVD_CONFLICT_DISTANCE = 0.04
+
+// Linear RGB matrices. The dichromacies are Machado, Oliveira and
+// Fernandes (2009) at severity 1, achromatopsia maps to luminance.
+var cvdMatrices = map[string][3][3]float64{
+	CVD_PROTANOPIA: {
+		{0.152286, 1.052583, -0.204868},
+		{0.114503, 0.786281, 0.099216},
+		{-0.003882, -0.048116, 1.051998},
+	},
+	CVD_DEUTERANOPIA: {
+		{0.367322, 0.860646, -0.227968},
+		{0.280085, 0.672501, 0.047413},
+		{-0.011820, 0.042940, 0.968881},
+	},
+	CVD_TRITANOPIA: {
+		{1.255528, -0.076749, -0.178779},
+		{-0.078411, 0.930809, 0.147602},
+		{0.004733, 0.691367, 0.303900},
+	},
+	CVD_ACHROMATOPSIA: {
+		{0.2126, 0.7152, 0.0722},
+		{0.2126, 0.7152, 0.0722},
+		{0.2126, 0.7152, 0.0722},
+	},
+}
+
+func cvdMatrix(kind string) ([3][3]float64, error) {
+	m, ok := cvdMatrices[kind]
+	if !ok {
+		return m, fmt.Errorf("Unknown color vision deficiency %q. Available: %v.", kind, CVD_TYPES)
+	}
+	return m, nil
+}
+
+// c as seen with the deficiency kind.
+func SimulateCVD(c color.NRGBA, kind string) (color.NRGBA, error) {
+	m, err := cvdMatrix(kind)
+	if err != nil {
+		return c, err
+	}
+	return applyCVD(c, &m, nil), nil
+}
+
+// Builds a simulated pixel. lut caches linearToSrgb in 4096 steps for
+// whole images, nil computes it exactly.
+func applyCVD(c color.NRGBA, m *[3][3]float64, lut []uint8) color.NRGBA {
+	r := srgbToLinear(float64(c.R) / 255)
+	g := srgbToLinear(float64(c.G) / 255)
+	b := srgbToLinear(float64(c.B) / 255)
+	var out [3]uint8
+	for i := range out {
+		v := clamp01(m[i][0]*r + m[i][1]*g + m[i][2]*b)
+		if lut != nil {
+			out[i] = lut[int(v*float64(len(lut)-1)+0.5)]
+		} else {
+			out[i] = to8Bit(linearToSrgb(v))
+		}
+	}
+	return color.NRGBA{R: out[0], G: out[1], B: out[2], A: c.A}
+}
+
+// Copy of img as seen with the deficiency kind.
+func SimulateCVDImage(img image.Image, kind string) (*image.NRGBA, error) {
+	m, err := cvdMatrix(kind)
+	if err != nil {
+		return nil, err
+	}
+	lut := make([]uint8, 4096)
+	for i := range lut {
+		lut[i] = to8Bit(linearToSrgb(float64(i) / float64(len(lut)-1)))
+	}
+
+	out := toNRGBA(img)
+	// Rows are split between goroutines, each pixel is independent.
+	workers := runtime.NumCPU()
+	h := out.Rect.Dy()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for y := w; y < h; y += workers {
+				row := out.Pix[y*out.Stride : y*out.Stride+4*out.Rect.Dx()]
+				for i := 0; i < len(row); i += 4 {
+					c := applyCVD(color.NRGBA{row[i], row[i+1], row[i+2], row[i+3]}, &m, lut)
+					row[i], row[i+1], row[i+2] = c.R, c.G, c.B
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	return out, nil
+}
+
+// Two palette colors that are told apart with normal vision but not with
+// the deficiency.
+type CVDConflict struct {
+	Condition string  `json:"condition"`
+	A         string  `json:"a"`
+	B         string  `json:"b"`
+	DeltaE    float64 `json:"deltaE"`
+	// ΔE of the simulated colors.
+	SimulatedDeltaE float64 `json:"simulatedDeltaE"`
+}
+
+// Palette pairs that fall within threshold (OKLab ΔE) of each other under
+// the deficiency kind while being further apart normally.
+func CVDConflicts(palette []ColAndFreq, kind string, threshold float64) ([]CVDConflict, error) {
+	m, err := cvdMatrix(kind)
+	if err != nil {
+		return nil, err
+	}
+	cols := make([]color.NRGBA, len(palette))
+	simulated := make([]color.NRGBA, len(palette))
+	for i, c := range palette {
+		cols[i] = HexToNRGBA(c.ColString)
+		simulated[i] = applyCVD(cols[i], &m, nil)
+	}
+
+	conflicts := make([]CVDConflict, 0)
+	for i := range cols {
+		for j := i + 1; j < len(cols); j++ {
+			d := DeltaEOK(cols[i], cols[j])
+			sd := DeltaEOK(simulated[i], simulated[j])
+			if d >= threshold && sd < threshold {
+				conflicts = append(conflicts, CVDConflict{
+					Condition:       kind,
+					A:               palette[i].ColString,
+					B:               palette[j].ColString,
+					DeltaE:          math.Round(d*1000) / 1000,
+					SimulatedDeltaE: math.Round(sd*1000) / 1000,
+				})
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// Everything a preview of one deficiency needs.
+type CVDSimulation struct {
+	Condition string        `json:"condition"`
+	Palette   []string      `json:"palette"`
+	Conflicts []CVDConflict `json:"conflicts"`
+	// nil when no image was given.
+	Image *image.NRGBA `json:"-"`
+}
+
+// Simulates kind on img (which may be nil) and palette, and lists the
+// palette conflicts at CVD_CONFLICT_DISTANCE.
+func SimulateCVDAll(img image.Image, palette []ColAndFreq, kind string) (CVDSimulation, error) {
+	sim := CVDSimulation{Condition: kind, Palette: make([]string, len(palette))}
+	for i, c := range palette {
+		col, err := SimulateCVD(HexToNRGBA(c.ColString), kind)
+		if err != nil {
+			return sim, err
+		}
+		sim.Palette[i] = NRGBAToHex(col)
+	}
+
+	var err error
+	sim.Conflicts, err = CVDConflicts(palette, kind, CVD_CONFLICT_DISTANCE)
+	if err != nil {
+		return sim, err
+	}
+	if img != nil {
+		sim.Image, err = SimulateCVDImage(img, kind)
+	}
+	return sim, err
+}
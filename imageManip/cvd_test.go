@@ -0,0 +1,52 @@
+package imageManip
+
+import (
+	"image/color"
+	"testing"
+)
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Off by one is allowed for float rounding.
+func nearNRGBA(a, b color.NRGBA) bool {
+	near := func(x, y uint8) bool { return abs(int(x)-int(y)) <= 1 }
+	return near(a.R, b.R) && near(a.G, b.G) && near(a.B, b.B) && near(a.A, b.A)
+}
+
+// Primaries through the Machado et al. (2009) severity 1 matrices, and
+// grays, which every simulation keeps.
+func TestSimulateCVD(t *testing.T) {
+	type test struct {
+		kind     string
+		in, want color.NRGBA
+	}
+	tests := []test{
+		{CVD_PROTANOPIA, color.NRGBA{255, 0, 0, 255}, color.NRGBA{109, 95, 0, 255}},
+		{CVD_DEUTERANOPIA, color.NRGBA{0, 255, 0, 255}, color.NRGBA{239, 214, 58, 255}},
+		{CVD_TRITANOPIA, color.NRGBA{0, 0, 255, 255}, color.NRGBA{0, 107, 150, 255}},
+		{CVD_ACHROMATOPSIA, color.NRGBA{0, 0, 255, 128}, color.NRGBA{76, 76, 76, 128}},
+	}
+	for _, kind := range CVD_TYPES {
+		for _, v := range []uint8{0, 128, 255} {
+			gray := color.NRGBA{v, v, v, 255}
+			tests = append(tests, test{kind, gray, gray})
+		}
+	}
+	for _, tt := range tests {
+		got, err := SimulateCVD(tt.in, tt.kind)
+		if err != nil {
+			t.Errorf("%s %v: %v", tt.kind, tt.in, err)
+		} else if !nearNRGBA(got, tt.want) {
+			t.Errorf("%s %v: got %v, want %v", tt.kind, tt.in, got, tt.want)
+		}
+	}
+
+	if _, err := SimulateCVD(color.NRGBA{}, "no-such-deficiency"); err == nil {
+		t.Error("no error for an unknown deficiency")
+	}
+}
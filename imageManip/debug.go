@@ -0,0 +1,22 @@
+package imageManip
+
+import (
+	"fmt"
+	"os"
+)
+
+// When set, the extractors report their progress on stderr. On from the
+// start if GOPALETTES_DEBUG is set.
+var Debug = os.Getenv("GOPALETTES_DEBUG") != ""
+
+func debugf(format string, args ...interface{}) {
+	if Debug {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
+
+func debugln(args ...interface{}) {
+	if Debug {
+		fmt.Fprintln(os.Stderr, args...)
+	}
+}
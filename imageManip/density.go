@@ -0,0 +1,225 @@
+package imageManip
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Mean-shift bandwidth in OKLab. Colors closer than this pull on each
+// other and end up in the same cluster.
+const DENSITY_BANDWIDTH = 0.06
+
+// Clusters with a smaller share of the pixels are dropped by the density
+// extractor, unless Options.MinShare says otherwise.
+const DENSITY_MIN_SHARE = 0.01
+
+const densityMaxIterations = 50
+
+// A 5-bit histogram bin with the exact sums of its pixels, so flat colors
+// come out unchanged.
+type densityBin struct {
+	lab     OKLab
+	r, g, b uint64
+	count   uint64
+}
+
+func (bin *densityBin) add(other *densityBin) {
+	bin.r += other.r
+	bin.g += other.g
+	bin.b += other.b
+	bin.count += other.count
+}
+
+func (bin *densityBin) color() color.NRGBA {
+	return color.NRGBA{
+		uint8(bin.r / bin.count),
+		uint8(bin.g / bin.count),
+		uint8(bin.b / bin.count),
+		255,
+	}
+}
+
+// Bins of a spatial hash over OKLab with cells one bandwidth wide, so the
+// neighbours of a point are in the 27 cells around it.
+type densityGrid struct {
+	bins  []densityBin
+	cells map[[3]int][]int
+	size  float64
+}
+
+func densityCell(lab OKLab, size float64) [3]int {
+	return [3]int{
+		int(math.Floor(lab.L / size)),
+		int(math.Floor(lab.A / size)),
+		int(math.Floor(lab.B / size)),
+	}
+}
+
+func newDensityGrid(bins []densityBin, size float64) *densityGrid {
+	grid := &densityGrid{bins: bins, cells: make(map[[3]int][]int), size: size}
+	for i := range bins {
+		cell := densityCell(bins[i].lab, size)
+		grid.cells[cell] = append(grid.cells[cell], i)
+	}
+	return grid
+}
+
+// Weighted mean of the bins within the bandwidth of p, and their weight.
+func (grid *densityGrid) mean(p OKLab) (OKLab, float64) {
+	var sum OKLab
+	weight := 0.0
+	center := densityCell(p, grid.size)
+	for dl := -1; dl <= 1; dl++ {
+		for da := -1; da <= 1; da++ {
+			for db := -1; db <= 1; db++ {
+				cell := [3]int{center[0] + dl, center[1] + da, center[2] + db}
+				for _, i := range grid.cells[cell] {
+					bin := &grid.bins[i]
+					if p.distance(bin.lab) > grid.size {
+						continue
+					}
+					w := float64(bin.count)
+					sum.L += bin.lab.L * w
+					sum.A += bin.lab.A * w
+					sum.B += bin.lab.B * w
+					weight += w
+				}
+			}
+		}
+	}
+	if weight == 0 {
+		return p, 0
+	}
+	return OKLab{sum.L / weight, sum.A / weight, sum.B / weight}, weight
+}
+
+// Moves p uphill until it settles on a peak of the color density.
+func (grid *densityGrid) shift(p OKLab) (OKLab, float64) {
+	weight := 0.0
+	for i := 0; i < densityMaxIterations; i++ {
+		var next OKLab
+		next, weight = grid.mean(p)
+		moved := p.distance(next)
+		p = next
+		if moved < grid.size*1e-3 {
+			break
+		}
+	}
+	return p, weight
+}
+
+// Opaque pixels of img in the 5-bit bins of getHisto.
+func densityBins(img image.Image) []densityBin {
+	byIndex := make(map[int]*densityBin)
+	eachPixel(img, func(c color.NRGBA) {
+		if c.A == 0 {
+			return
+		}
+		index := getColorIndex(int(c.R)>>RSHIFT, int(c.G)>>RSHIFT, int(c.B)>>RSHIFT)
+		bin, ok := byIndex[index]
+		if !ok {
+			bin = &densityBin{}
+			byIndex[index] = bin
+		}
+		bin.r += uint64(c.R)
+		bin.g += uint64(c.G)
+		bin.b += uint64(c.B)
+		bin.count++
+	})
+
+	bins := make([]densityBin, 0, len(byIndex))
+	for _, bin := range byIndex {
+		bin.lab = NRGBAToOKLab(bin.color())
+		bins = append(bins, *bin)
+	}
+	return bins
+}
+
+// Mean-shift clustering of the color histogram in OKLab. The number of
+// colors is not chosen up front: every peak of the color density is a
+// color, and peaks with less than minShare of the pixels are dropped.
+// The length of the result is the natural palette size of the image.
+func ExtractPaletteDensity(img image.Image, minShare float64, goroutines int) []ColAndFreq {
+	bins := densityBins(img)
+	if len(bins) == 0 {
+		return nil
+	}
+	if goroutines < 1 {
+		goroutines = 1
+	}
+	grid := newDensityGrid(bins, DENSITY_BANDWIDTH)
+
+	// Every bin is a seed.
+	peaks := make([]OKLab, len(bins))
+	heights := make([]float64, len(bins))
+	var wg sync.WaitGroup
+	chunk := (len(bins) + goroutines - 1) / goroutines
+	for start := 0; start < len(bins); start += chunk {
+		end := start + chunk
+		if end > len(bins) {
+			end = len(bins)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				peaks[i], heights[i] = grid.shift(bins[i].lab)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	// Seeds that climbed to the same peak stop a little apart, the
+	// highest one within a bandwidth stands for all of them.
+	order := make([]int, len(peaks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return heights[order[i]] > heights[order[j]]
+	})
+	var modes []OKLab
+	for _, i := range order {
+		duplicate := false
+		for _, mode := range modes {
+			if mode.distance(peaks[i]) < DENSITY_BANDWIDTH {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			modes = append(modes, peaks[i])
+		}
+	}
+
+	clusters := make([]densityBin, len(modes))
+	total := uint64(0)
+	for i := range bins {
+		nearest, best := 0, math.Inf(1)
+		for m, mode := range modes {
+			if d := mode.distance(peaks[i]); d < best {
+				nearest, best = m, d
+			}
+		}
+		clusters[nearest].add(&bins[i])
+		total += bins[i].count
+	}
+
+	var palette []ColAndFreq
+	for i := range clusters {
+		if clusters[i].count == 0 || float64(clusters[i].count) < minShare*float64(total) {
+			continue
+		}
+		palette = append(palette, ColAndFreq{
+			ColString: NRGBAToHex(clusters[i].color()),
+			Frequency: int(clusters[i].count),
+		})
+	}
+	sort.SliceStable(palette, func(i, j int) bool {
+		return palette[i].Frequency > palette[j].Frequency
+	})
+	return palette
+}
@@ -0,0 +1,183 @@
+package imageManip
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"text/tabwriter"
+)
+
+// Matched colors further apart than this in OKLab count as one color
+// removed and another added, unless DiffPalettes is told otherwise.
+const DIFF_MAX_DELTA_E = 0.15
+
+// Below this OKLCH chroma a color is gray enough that its hue means
+// nothing, hue shifts involving one are reported as 0.
+const diffGrayChroma = 0.02
+
+// How one color of the old palette moved to its match in the new one.
+// Lightness, chroma and hue are OKLCH differences, new minus old, hue in
+// degrees between -180 and 180. Shares are the color's part of its
+// palette's frequencies.
+type ColorShift struct {
+	From      string  `json:"from"`
+	To        string  `json:"to"`
+	DeltaE    float64 `json:"deltaE"`
+	Lightness float64 `json:"lightness"`
+	Chroma    float64 `json:"chroma"`
+	Hue       float64 `json:"hue"`
+	FromShare float64 `json:"fromShare"`
+	ToShare   float64 `json:"toShare"`
+}
+
+type PaletteDiff struct {
+	Matched []ColorShift `json:"matched"`
+	Removed []ColAndFreq `json:"removed"`
+	Added   []ColAndFreq `json:"added"`
+	// Mean ΔE of the matched colors.
+	MeanDeltaE float64 `json:"meanDeltaE"`
+}
+
+// Pairs the colors of before and after so the total ΔE (OKLab) is as
+// small as possible, with the Hungarian algorithm. Pairs further apart
+// than maxDeltaE (DIFF_MAX_DELTA_E if <= 0) and colors left over when the
+// palettes differ in size are reported as removed or added. Matched is in
+// the order of before.
+func DiffPalettes(before, after []ColAndFreq, maxDeltaE float64) PaletteDiff {
+	if maxDeltaE <= 0 {
+		maxDeltaE = DIFF_MAX_DELTA_E
+	}
+	beforeLabs, beforeShares := weightedLabs(before)
+	afterLabs, afterShares := weightedLabs(after)
+
+	// Padded to a square matrix. Every pair past the threshold costs the
+	// same, so they don't pull the real matches around.
+	n := max(len(before), len(after))
+	cost := make([][]float64, n)
+	for i := range cost {
+		cost[i] = make([]float64, n)
+		for j := range cost[i] {
+			if i < len(before) && j < len(after) {
+				cost[i][j] = math.Min(beforeLabs[i].distance(afterLabs[j]), maxDeltaE)
+			}
+		}
+	}
+	assignment := hungarian(cost)
+
+	diff := PaletteDiff{Matched: []ColorShift{}, Removed: []ColAndFreq{}, Added: []ColAndFreq{}}
+	matchedAfter := make([]bool, len(after))
+	for i := range before {
+		j := assignment[i]
+		if j >= len(after) || beforeLabs[i].distance(afterLabs[j]) > maxDeltaE {
+			diff.Removed = append(diff.Removed, before[i])
+			continue
+		}
+		matchedAfter[j] = true
+		from, to := beforeLabs[i].LCH(), afterLabs[j].LCH()
+		shift := ColorShift{
+			From:      before[i].ColString,
+			To:        after[j].ColString,
+			DeltaE:    beforeLabs[i].distance(afterLabs[j]),
+			Lightness: to.L - from.L,
+			Chroma:    to.C - from.C,
+			FromShare: beforeShares[i],
+			ToShare:   afterShares[j],
+		}
+		if from.C >= diffGrayChroma && to.C >= diffGrayChroma {
+			shift.Hue = math.Mod(to.H-from.H+540, 360) - 180
+		}
+		diff.Matched = append(diff.Matched, shift)
+		diff.MeanDeltaE += shift.DeltaE
+	}
+	for j := range after {
+		if !matchedAfter[j] {
+			diff.Added = append(diff.Added, after[j])
+		}
+	}
+	if len(diff.Matched) > 0 {
+		diff.MeanDeltaE /= float64(len(diff.Matched))
+	}
+	return diff
+}
+
+// Writes the diff as a table, one line per color.
+func (d PaletteDiff) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "before\tafter\tΔE\tlightness\tchroma\thue")
+	for _, s := range d.Matched {
+		fmt.Fprintf(tw, "%s\t%s\t%.3f\t%+.3f\t%+.3f\t%+.1f°\n", s.From, s.To, s.DeltaE, s.Lightness, s.Chroma, s.Hue)
+	}
+	for _, c := range d.Removed {
+		fmt.Fprintf(tw, "%s\t-\tremoved\n", c.ColString)
+	}
+	for _, c := range d.Added {
+		fmt.Fprintf(tw, "-\t%s\tadded\n", c.ColString)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d matched (mean ΔE %.3f), %d removed, %d added.\n",
+		len(d.Matched), d.MeanDeltaE, len(d.Removed), len(d.Added))
+	return err
+}
+
+// Minimum cost assignment of a square cost matrix: row i gets column
+// ret[i]. The O(n³) version with potentials, rows are added one at a
+// time and matched along a shortest augmenting path.
+func hungarian(cost [][]float64) []int {
+	n := len(cost)
+	// 1-based, index 0 stands for "no row" / the start column.
+	u := make([]float64, n+1)
+	v := make([]float64, n+1)
+	rowOf := make([]int, n+1)
+	way := make([]int, n+1)
+	minv := make([]float64, n+1)
+	used := make([]bool, n+1)
+
+	for i := 1; i <= n; i++ {
+		rowOf[0] = i
+		col := 0
+		for j := range minv {
+			minv[j] = math.Inf(1)
+			used[j] = false
+		}
+		for rowOf[col] != 0 {
+			used[col] = true
+			row := rowOf[col]
+			delta, next := math.Inf(1), 0
+			for j := 1; j <= n; j++ {
+				if used[j] {
+					continue
+				}
+				if c := cost[row-1][j-1] - u[row] - v[j]; c < minv[j] {
+					minv[j], way[j] = c, col
+				}
+				if minv[j] < delta {
+					delta, next = minv[j], j
+				}
+			}
+			for j := 0; j <= n; j++ {
+				if used[j] {
+					u[rowOf[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			col = next
+		}
+		for col != 0 {
+			prev := way[col]
+			rowOf[col] = rowOf[prev]
+			col = prev
+		}
+	}
+
+	ret := make([]int, n)
+	for j := 1; j <= n; j++ {
+		if rowOf[j] != 0 {
+			ret[rowOf[j]-1] = j - 1
+		}
+	}
+	return ret
+}
@@ -0,0 +1,66 @@
+package imageManip
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Small cases whose optimum was checked by hand against every permutation.
+func TestHungarian(t *testing.T) {
+	tests := []struct {
+		name string
+		cost [][]float64
+		want []int
+	}{
+		{"empty", [][]float64{}, []int{}},
+		{"single", [][]float64{{7}}, []int{0}},
+		{
+			// 1 + 2 + 2 = 5, the greedy pick of the 0 leads to 6.
+			"3x3",
+			[][]float64{
+				{4, 1, 3},
+				{2, 0, 5},
+				{3, 2, 2},
+			},
+			[]int{1, 0, 2},
+		},
+		{
+			// 2 + 6 + 1 + 4 = 13.
+			"4x4",
+			[][]float64{
+				{9, 2, 7, 8},
+				{6, 4, 3, 7},
+				{5, 8, 1, 8},
+				{7, 6, 9, 4},
+			},
+			[]int{1, 0, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		if got := hungarian(tt.cost); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffPalettes(t *testing.T) {
+	before := []ColAndFreq{{"#ff0000", 1}, {"#00ff00", 1}, {"#0000ff", 1}}
+	// Reordered, red nudged and blue swapped for white.
+	after := []ColAndFreq{{"#00ff00", 1}, {"#fa0000", 1}, {"#ffffff", 1}}
+	diff := DiffPalettes(before, after, 0)
+
+	var matched [][2]string
+	for _, s := range diff.Matched {
+		matched = append(matched, [2]string{s.From, s.To})
+	}
+	wantMatched := [][2]string{{"#ff0000", "#fa0000"}, {"#00ff00", "#00ff00"}}
+	if !reflect.DeepEqual(matched, wantMatched) {
+		t.Errorf("matched %v, want %v", matched, wantMatched)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].ColString != "#0000ff" {
+		t.Errorf("removed %v, want #0000ff", diff.Removed)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].ColString != "#ffffff" {
+		t.Errorf("added %v, want #ffffff", diff.Added)
+	}
+}
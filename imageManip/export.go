@@ -0,0 +1,311 @@
+package imageManip
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// What export templates are executed with.
+type ExportData struct {
+	Name string
+	// Whether Background is dark, for targets that care (VS Code).
+	Dark       bool
+	Background string
+	Foreground string
+	Colors     []ExportColor
+}
+
+type ExportColor struct {
+	// "color-1" style name for CSS and friends, GoName is an exported Go
+	// identifier.
+	Name      string
+	GoName    string
+	Hex       string
+	R, G, B   uint8
+	Frequency int
+	// Tailwind 50 to 950 shades.
+	Shades []Shade
+	// Readable on Background, for UI elements and syntax colors.
+	OnBackground string
+	// Nearest named color, {{.ColorName.Label}} gives "navy" or "navy-ish".
+	ColorName ColorName
+}
+
+// Built in export formats and the file extension they're saved with.
+var EXPORT_FORMATS = []string{"css", "scss", "tailwind", "vscode", "go"}
+
+var exportExtensions = map[string]string{
+	"css":      "css",
+	"scss":     "scss",
+	"tailwind": "js",
+	"vscode":   "json",
+	"go":       "go",
+}
+
+var exportTemplates = map[string]string{
+	"css": `/* {{.Name}} */
+:root {
+{{- range .Colors}}
+  --{{.Name}}: {{.Hex}}; /* {{.ColorName.Label}} */
+{{- end}}
+}
+`,
+	"scss": `// {{.Name}}
+{{- range .Colors}}
+${{.Name}}: {{.Hex}}; // {{.ColorName.Label}}
+{{- end}}
+`,
+	"tailwind": `// {{.Name}}
+module.exports = {
+  theme: {
+    colors: {
+{{- range .Colors}}
+      // {{.ColorName.Label}}
+      {{json .Name}}: {
+        DEFAULT: {{json .Hex}},
+{{- range .Shades}}
+        {{.Step}}: {{json .Hex}},
+{{- end}}
+      },
+{{- end}}
+    },
+  },
+};
+`,
+	"vscode": `{
+  "name": {{json .Name}},
+  "type": "{{if .Dark}}dark{{else}}light{{end}}",
+  "colors": {
+    "editor.background": {{json .Background}},
+    "editor.foreground": {{json .Foreground}},
+    "sideBar.background": {{json .Background}},
+    "activityBar.background": {{json .Background}},
+    "titleBar.activeBackground": {{json .Background}},
+    "tab.activeBackground": {{json .Background}},
+    "statusBar.background": {{json (color 0).Hex}},
+    "focusBorder": {{json (color 0).OnBackground}},
+    "editorCursor.foreground": {{json (color 0).OnBackground}},
+    "button.background": {{json (color 0).Hex}},
+    "badge.background": {{json (color 1).Hex}}
+  },
+  "tokenColors": [
+    {"name": {{json (printf "Comments, %s" (color 4).ColorName.Label)}}, "scope": "comment", "settings": {"foreground": {{json (color 4).OnBackground}}, "fontStyle": "italic"}},
+    {"name": {{json (printf "Keywords, %s" (color 0).ColorName.Label)}}, "scope": "keyword", "settings": {"foreground": {{json (color 0).OnBackground}}}},
+    {"name": {{json (printf "Strings, %s" (color 1).ColorName.Label)}}, "scope": "string", "settings": {"foreground": {{json (color 1).OnBackground}}}},
+    {"name": {{json (printf "Functions, %s" (color 2).ColorName.Label)}}, "scope": ["entity.name.function", "support.function"], "settings": {"foreground": {{json (color 2).OnBackground}}}},
+    {"name": {{json (printf "Types, %s" (color 3).ColorName.Label)}}, "scope": ["entity.name.type", "support.type"], "settings": {"foreground": {{json (color 3).OnBackground}}}},
+    {"name": {{json (printf "Constants, %s" (color 1).ColorName.Label)}}, "scope": ["constant", "variable.other.constant"], "settings": {"foreground": {{json (color 1).OnBackground}}}},
+    {"name": "Variables", "scope": "variable", "settings": {"foreground": {{json .Foreground}}}}
+  ]
+}
+`,
+	"go": `// Code generated by goPalettes. DO NOT EDIT.
+
+// {{.Name}}
+package palette
+
+import "image/color"
+
+var (
+{{- range .Colors}}
+	{{.GoName}} = color.NRGBA{R: {{printf "0x%02x" .R}}, G: {{printf "0x%02x" .G}}, B: {{printf "0x%02x" .B}}, A: 0xff} // {{.Hex}}, {{.ColorName.Label}}
+{{- end}}
+)
+
+var Palette = []color.NRGBA{
+{{- range .Colors}}
+	{{.GoName}},
+{{- end}}
+}
+`,
+}
+
+// Palette colors with everything the templates use precomputed.
+func NewExportData(name string, palette []ColAndFreq) ExportData {
+	lchs := make([]OKLCH, len(palette))
+	for i, c := range palette {
+		lchs[i] = NRGBAToOKLCH(HexToNRGBA(c.ColString))
+	}
+
+	// Background and foreground are tinted towards the darkest and
+	// lightest palette colors, so they match the image without being
+	// hard to read on.
+	darkest, lightest := OKLCH{L: 0.2}, OKLCH{L: 0.9}
+	for i, lch := range lchs {
+		if i == 0 || lch.L < darkest.L {
+			darkest = lch
+		}
+		if i == 0 || lch.L > lightest.L {
+			lightest = lch
+		}
+	}
+	background := OKLCHToNRGBA(OKLCH{L: 0.2, C: minFloat(darkest.C, 0.02), H: darkest.H})
+	foreground := EnsureContrast(
+		OKLCHToNRGBA(OKLCH{L: 0.9, C: minFloat(lightest.C, 0.02), H: lightest.H}),
+		background,
+		TERMINAL_MIN_FG_CONTRAST,
+	)
+
+	data := ExportData{
+		Name:       name,
+		Dark:       true,
+		Background: NRGBAToHex(background),
+		Foreground: NRGBAToHex(foreground),
+		Colors:     make([]ExportColor, len(palette)),
+	}
+	for i, c := range palette {
+		col := HexToNRGBA(c.ColString)
+		data.Colors[i] = ExportColor{
+			Name:         "color-" + strconv.Itoa(i+1),
+			GoName:       "Color" + strconv.Itoa(i+1),
+			Hex:          c.ColString,
+			R:            col.R,
+			G:            col.G,
+			B:            col.B,
+			Frequency:    c.Frequency,
+			Shades:       TailwindShades(col),
+			OnBackground: NRGBAToHex(EnsureContrast(col, background, TERMINAL_MIN_CONTRAST)),
+		}
+		data.Colors[i].ColorName, _ = NearestColorName(col, COLOR_NAMES_ALL)
+	}
+	return data
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Functions available to every export template, user ones included.
+//
+//	json     value as JSON, for strings in JSON and JS output
+//	upper    strings.ToUpper
+//	lower    strings.ToLower
+//	trimHash hex code without the leading "#"
+//	add      integer addition, e.g. {{add $i 1}}
+//	color    n'th palette color, wrapping around short palettes
+func exportFuncs(data ExportData) template.FuncMap {
+	return template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"upper":    strings.ToUpper,
+		"lower":    strings.ToLower,
+		"trimHash": func(hex string) string { return strings.TrimPrefix(hex, "#") },
+		"add":      func(a, b int) int { return a + b },
+		"color": func(i int) ExportColor {
+			if len(data.Colors) == 0 {
+				return ExportColor{Hex: data.Foreground, OnBackground: data.Foreground}
+			}
+			return data.Colors[i%len(data.Colors)]
+		},
+	}
+}
+
+// Folder user templates are read from, goPalettes/templates in the user
+// config directory. A file named "kitty.conf.tmpl" adds the format
+// "kitty", saved with the "conf" extension.
+func ExportTemplateDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "goPalettes", "templates"), nil
+}
+
+// Built in formats followed by the ones in ExportTemplateDir.
+func ExportFormats() []string {
+	formats := append([]string{}, EXPORT_FORMATS...)
+	userFormats := make([]string, 0)
+	for format := range userTemplates() {
+		if _, builtIn := exportTemplates[format]; !builtIn {
+			userFormats = append(userFormats, format)
+		}
+	}
+	sort.Strings(userFormats)
+	return append(formats, userFormats...)
+}
+
+// File extension to save format with.
+func ExportExtension(format string) string {
+	if ext, ok := exportExtensions[format]; ok {
+		return ext
+	}
+	if path, ok := userTemplates()[format]; ok {
+		return templateExtension(path)
+	}
+	if strings.HasSuffix(format, ".tmpl") {
+		return templateExtension(format)
+	}
+	return "txt"
+}
+
+// Format name to template file path.
+func userTemplates() map[string]string {
+	templates := make(map[string]string)
+	dir, err := ExportTemplateDir()
+	if err != nil {
+		return templates
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return templates
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".tmpl") {
+			continue
+		}
+		name := strings.TrimSuffix(f.Name(), ".tmpl")
+		if i := strings.Index(name, "."); i > 0 {
+			name = name[:i]
+		}
+		templates[name] = filepath.Join(dir, f.Name())
+	}
+	return templates
+}
+
+// "a/kitty.conf.tmpl" -> "conf"
+func templateExtension(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+	if ext := filepath.Ext(name); ext != "" {
+		return ext[1:]
+	}
+	return "txt"
+}
+
+// Writes data through the named format. format is a built in format, a
+// template from ExportTemplateDir or the path of a .tmpl file.
+func WriteExport(w io.Writer, format string, data ExportData) error {
+	text, ok := exportTemplates[format]
+	if !ok {
+		path, isUser := userTemplates()[format]
+		if !isUser {
+			if !strings.HasSuffix(format, ".tmpl") {
+				return fmt.Errorf("Unknown export format %q. Available: %v.", format, ExportFormats())
+			}
+			path = format
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		text = string(b)
+	}
+
+	tmpl, err := template.New(format).Funcs(exportFuncs(data)).Parse(text)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, data)
+}
@@ -0,0 +1,173 @@
+package imageManip
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Settings shared by all extractors. Extractors ignore the ones that
+// don't apply to them.
+type Options struct {
+	// Number of colors to extract.
+	Count int `json:"count"`
+	// Distance under which colors are merged (frequency extractors).
+	Tolerance float64 `json:"tolerance"`
+	// Goroutines used inside a single extraction.
+	Goroutines int `json:"goroutines"`
+	// Only every Sample'th pixel in each direction is looked at.
+	// 0 or 1 uses all pixels.
+	Sample int `json:"sample"`
+	// Smallest share of the pixels a cluster (density extractor) or a
+	// color (frequency extractor) needs to be kept. 0 uses
+	// DENSITY_MIN_SHARE or MIN_COLOR_SHARE.
+	MinShare float64 `json:"minShare,omitempty"`
+	// Pixel weighting, one of WEIGHTINGS or "" to count every pixel the
+	// same (frequency extractor).
+	Weighting string `json:"weighting,omitempty"`
+	// Grayscale image whose brightness weights the pixels (frequency
+	// extractor).
+	WeightMap string `json:"weightMap,omitempty"`
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Count:      5,
+		Tolerance:  10,
+		Goroutines: runtime.NumCPU(),
+	}
+}
+
+// A palette extraction algorithm.
+type Extractor func(img image.Image, opts Options) ([]ColAndFreq, error)
+
+const DEFAULT_EXTRACTOR = "frequency"
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   = map[string]Extractor{
+		// ExtractPaletteConcurrent, what the GUI uses.
+		// With Weighting or WeightMap set, ExtractPaletteWeighted.
+		"frequency": func(img image.Image, opts Options) ([]ColAndFreq, error) {
+			weights, err := optionWeights(img, opts)
+			if err != nil {
+				return nil, err
+			}
+			if weights != nil {
+				return ExtractPaletteWeighted(img, weights, opts.Count, opts.Goroutines, opts.Tolerance, opts.MinShare), nil
+			}
+			colorFrequencyMap := CreateColorFrequencyMap(img)
+			return paletteFromColFreqMap(colorFrequencyMap, opts.Count, opts.Goroutines, opts.Tolerance, opts.MinShare), nil
+		},
+		// ExtractPalette, single threaded merging.
+		"frequency-simple": func(img image.Image, opts Options) ([]ColAndFreq, error) {
+			return ExtractPalette(img, opts.Count, opts.Tolerance), nil
+		},
+		// Octree quantization, bounded memory for huge images.
+		"octree": func(img image.Image, opts Options) ([]ColAndFreq, error) {
+			return ExtractPaletteOctree(img, opts.Count)
+		},
+		// Wu's variance minimizing quantizer over the 5-bit histogram.
+		"wu": func(img image.Image, opts Options) ([]ColAndFreq, error) {
+			return ExtractPaletteWu(img, opts.Count)
+		},
+		// Modified median cut (Color Thief), GetPalette. Frequencies are
+		// the pixels in each color's box.
+		"mmcq": func(img image.Image, opts Options) ([]ColAndFreq, error) {
+			return GetPalette(img, opts.Count)
+		},
+		// Mean-shift in OKLab, finds the number of colors itself and
+		// ignores Count.
+		"density": func(img image.Image, opts Options) ([]ColAndFreq, error) {
+			minShare := opts.MinShare
+			if minShare <= 0 {
+				minShare = DENSITY_MIN_SHARE
+			}
+			return ExtractPaletteDensity(img, minShare, opts.Goroutines), nil
+		},
+	}
+)
+
+// Makes an extractor selectable by name. Registering an existing name
+// replaces it.
+func RegisterExtractor(name string, e Extractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	extractors[name] = e
+}
+
+func GetExtractor(name string) (Extractor, error) {
+	extractorsMu.RLock()
+	e, ok := extractors[name]
+	extractorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown algorithm %q. Available: %v.", name, ExtractorNames())
+	}
+	return e, nil
+}
+
+// Sorted names of all registered extractors.
+func ExtractorNames() []string {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	names := make([]string, 0, len(extractors))
+	for name := range extractors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Runs the named extractor. Panics on the calling goroutine are returned
+// as errors. Panics in goroutines the extractor starts itself can't be
+// recovered here and still end the program.
+func Extract(name string, img image.Image, opts Options) (colors []ColAndFreq, err error) {
+	e, err := GetExtractor(name)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Goroutines < 1 {
+		opts.Goroutines = 1
+	}
+	img = SampleImage(img, opts.Sample)
+
+	defer func() {
+		if r := recover(); r != nil {
+			colors = nil
+			err = fmt.Errorf("In Extract: %s failed: %v", name, r)
+		}
+	}()
+	return e(img, opts)
+}
+
+// Every step'th pixel of the wrapped image in both directions.
+type sampledImage struct {
+	img  image.Image
+	step int
+}
+
+func (s sampledImage) ColorModel() color.Model {
+	return s.img.ColorModel()
+}
+
+func (s sampledImage) Bounds() image.Rectangle {
+	b := s.img.Bounds()
+	return image.Rect(0, 0, (b.Dx()+s.step-1)/s.step, (b.Dy()+s.step-1)/s.step)
+}
+
+func (s sampledImage) At(x, y int) color.Color {
+	b := s.img.Bounds()
+	return s.img.At(b.Min.X+x*s.step, b.Min.Y+y*s.step)
+}
+
+// Returns a view of img that only has every step'th pixel in each
+// direction. Used to trade accuracy for speed on large images.
+func SampleImage(img image.Image, step int) image.Image {
+	if step <= 1 {
+		return img
+	}
+	return sampledImage{img, step}
+}
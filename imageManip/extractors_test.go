@@ -0,0 +1,156 @@
+package imageManip
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Image made of the given colors in vertical stripes of 10 pixels.
+func stripes(colors ...color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 10*len(colors), 10))
+	for x := 0; x < img.Rect.Dx(); x++ {
+		for y := 0; y < 10; y++ {
+			img.SetNRGBA(x, y, colors[x/10])
+		}
+	}
+	return img
+}
+
+func sortedHexCodes(palette []ColAndFreq) []string {
+	ret := make([]string, len(palette))
+	for i, c := range palette {
+		ret[i] = c.ColString
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Asking for more colors than an image has used to panic in the frequency
+// extractor and fail in mmcq. Every extractor should return the colors
+// there are.
+func TestExtractFewColors(t *testing.T) {
+	img := stripes(
+		color.NRGBA{200, 30, 30, 255},
+		color.NRGBA{30, 200, 30, 255},
+		color.NRGBA{30, 30, 200, 255},
+	)
+	want := []string{"#1e1ec8", "#1ec81e", "#c81e1e"}
+	for _, name := range ExtractorNames() {
+		for _, count := range []int{3, 5, 16} {
+			opts := DefaultOptions()
+			opts.Count = count
+			palette, err := Extract(name, img, opts)
+			if err != nil {
+				t.Errorf("%s, %d colors: %v", name, count, err)
+				continue
+			}
+			if len(palette) > 3 {
+				t.Errorf("%s, %d colors: got %d colors from an image with 3", name, count, len(palette))
+			}
+			for _, c := range palette {
+				if c.Frequency <= 0 {
+					t.Errorf("%s, %d colors: %s has frequency %d", name, count, c.ColString, c.Frequency)
+				}
+			}
+		}
+	}
+
+	// Extractors that don't work on a reduced histogram (like wu and mmcq)
+	// return the colors exactly.
+	for _, name := range []string{"frequency", "frequency-simple", "octree"} {
+		opts := DefaultOptions()
+		opts.Count = 5
+		palette, err := Extract(name, img, opts)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if got := sortedHexCodes(palette); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+		}
+	}
+}
+
+// Gradients with noise on top, so that hardly any exact color repeats,
+// like in a photo.
+func noisyImage(w, h int) *image.NRGBA {
+	r := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{
+				uint8(x*200/w + r.Intn(56)),
+				uint8(y*200/h + r.Intn(56)),
+				uint8(r.Intn(256)),
+				255,
+			})
+		}
+	}
+	return img
+}
+
+// Cutting off rare colors before merging used to leave nothing of a noisy
+// image. frequency-simple merges every color and takes too long for a
+// test, density picks the number of colors itself.
+func TestExtractNoisyImage(t *testing.T) {
+	img := noisyImage(300, 200)
+	for _, name := range []string{"frequency", "octree", "wu"} {
+		opts := DefaultOptions()
+		opts.Count = 8
+		palette, err := Extract(name, img, opts)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if len(palette) != opts.Count {
+			t.Errorf("%s: got %d colors, want %d", name, len(palette), opts.Count)
+		}
+	}
+	for _, weighting := range WEIGHTINGS {
+		opts := DefaultOptions()
+		opts.Count = 8
+		opts.Weighting = weighting
+		palette, err := Extract("frequency", img, opts)
+		if err != nil {
+			t.Errorf("frequency, %s weighting: %v", weighting, err)
+		} else if len(palette) != opts.Count {
+			t.Errorf("frequency, %s weighting: got %d colors, want %d", weighting, len(palette), opts.Count)
+		}
+	}
+}
+
+// Only the pixels inside the bounds of a sub-image count, also when they
+// don't start at (0, 0).
+func TestExtractSubImage(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(img, img.Rect, image.NewUniform(color.NRGBA{A: 255}), image.Point{}, draw.Src)
+	inner := stripes(
+		color.NRGBA{200, 30, 30, 255},
+		color.NRGBA{30, 200, 30, 255},
+		color.NRGBA{30, 30, 200, 255},
+	)
+	r := image.Rect(40, 50, 70, 60)
+	draw.Draw(img, r, inner, image.Point{}, draw.Src)
+	sub := img.SubImage(r)
+
+	want := []string{"#1e1ec8", "#1ec81e", "#c81e1e"}
+	for _, weighting := range append([]string{""}, WEIGHTINGS...) {
+		opts := DefaultOptions()
+		opts.Weighting = weighting
+		palette, err := Extract("frequency", sub, opts)
+		if err != nil {
+			t.Errorf("%q weighting: %v", weighting, err)
+		} else if got := sortedHexCodes(palette); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q weighting: got %v, want %v", weighting, got, want)
+		}
+	}
+}
+
+func TestExtractUnknownAlgorithm(t *testing.T) {
+	if _, err := Extract("no-such-algorithm", stripes(color.NRGBA{A: 255}), DefaultOptions()); err == nil {
+		t.Error("no error for an unknown algorithm")
+	}
+}
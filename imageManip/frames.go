@@ -0,0 +1,101 @@
+package imageManip
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// How palettes are extracted from animated images.
+const (
+	// Palette of a single frame.
+	FRAME_MODE_SINGLE = "frame"
+	// One palette for the colors of all frames together.
+	FRAME_MODE_MERGED = "merged"
+	// One palette per frame.
+	FRAME_MODE_TIMELINE = "timeline"
+)
+
+type FramePalette struct {
+	// Index of the frame, -1 for a merged palette.
+	Frame int `json:"frame"`
+	// Delay in 100ths of a second.
+	Delay   int          `json:"delay"`
+	Palette []ColAndFreq `json:"palette"`
+}
+
+// Extracts palettes from frames according to mode with the named
+// extractor. frame selects the frame for FRAME_MODE_SINGLE and is ignored
+// otherwise.
+func ExtractFramesPalette(
+	frames Frames,
+	mode string,
+	frame int,
+	algorithm string,
+	opts Options,
+) ([]FramePalette, error) {
+	if len(frames.Images) == 0 {
+		return nil, errors.New("In ExtractFramesPalette: no frames.")
+	}
+
+	switch mode {
+	case FRAME_MODE_SINGLE:
+		if frame < 0 || frame >= len(frames.Images) {
+			return nil, fmt.Errorf("In ExtractFramesPalette: frame %d out of range.", frame)
+		}
+		palette, err := Extract(algorithm, frames.Images[frame], opts)
+		if err != nil {
+			return nil, err
+		}
+		return []FramePalette{{frame, frames.Delays[frame], palette}}, nil
+	case FRAME_MODE_MERGED:
+		palette, err := Extract(algorithm, stackedImage(frames.Images), opts)
+		if err != nil {
+			return nil, err
+		}
+		return []FramePalette{{-1, 0, palette}}, nil
+	case FRAME_MODE_TIMELINE:
+		ret := make([]FramePalette, len(frames.Images))
+		for i, img := range frames.Images {
+			palette, err := Extract(algorithm, img, opts)
+			if err != nil {
+				return nil, err
+			}
+			ret[i] = FramePalette{Frame: i, Delay: frames.Delays[i], Palette: palette}
+		}
+		return ret, nil
+	default:
+		return nil, fmt.Errorf("In ExtractFramesPalette: unknown frame mode %q.", mode)
+	}
+}
+
+// The frames one below the other as a single image, so any extractor can
+// count the colors of all of them together. Weightings see the stack as
+// one tall image.
+type stackedFrames []image.Image
+
+func stackedImage(frames []image.Image) image.Image {
+	if len(frames) == 1 {
+		return frames[0]
+	}
+	return stackedFrames(frames)
+}
+
+func (s stackedFrames) ColorModel() color.Model {
+	return s[0].ColorModel()
+}
+
+func (s stackedFrames) Bounds() image.Rectangle {
+	b := s[0].Bounds()
+	return image.Rect(0, 0, b.Dx(), b.Dy()*len(s))
+}
+
+func (s stackedFrames) At(x, y int) color.Color {
+	b := s[0].Bounds()
+	i := y / b.Dy()
+	if y < 0 || i >= len(s) {
+		return color.NRGBA{}
+	}
+	return s[i].At(b.Min.X+x, s[i].Bounds().Min.Y+y%b.Dy())
+}
@@ -0,0 +1,206 @@
+package imageManip
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"sync"
+)
+
+// Dominant colors by location: the image is cut into a grid and each
+// tile gets its own one color palette.
+type SpatialPalette struct {
+	Columns int `json:"columns"`
+	Rows    int `json:"rows"`
+	// Hex codes, Colors[row][column].
+	Colors [][]string `json:"colors"`
+	// One left to right gradient per row.
+	Gradients []string `json:"gradients"`
+	// CSS background value stacking the row gradients.
+	CSS string `json:"css"`
+	// The matrix as a PNG data URI, one pixel per tile. Scaled up with
+	// smoothing it makes a placeholder background.
+	DataURI string `json:"dataURI"`
+}
+
+// Cuts img into columns x rows domains. The last column and row pick up
+// the remainder pixels, like CreateDomains.
+func CreateGridDomains(img image.Image, columns, rows int) (domains []Domain) {
+	b := img.Bounds()
+	xChunk := b.Dx() / columns
+	yChunk := b.Dy() / rows
+	for row := 0; row < rows; row++ {
+		for col := 0; col < columns; col++ {
+			dom := Domain{
+				xLower: b.Min.X + xChunk*col,
+				xUpper: b.Min.X + xChunk*(col+1),
+				yLower: b.Min.Y + yChunk*row,
+				yUpper: b.Min.Y + yChunk*(row+1),
+			}
+			if col == columns-1 {
+				dom.xUpper = b.Max.X
+			}
+			if row == rows-1 {
+				dom.yUpper = b.Max.Y
+			}
+			domains = append(domains, dom)
+		}
+	}
+	return
+}
+
+// The part of an image inside a domain.
+type domainImage struct {
+	img image.Image
+	dom Domain
+}
+
+func (d domainImage) ColorModel() color.Model {
+	return d.img.ColorModel()
+}
+
+func (d domainImage) Bounds() image.Rectangle {
+	return image.Rect(d.dom.xLower, d.dom.yLower, d.dom.xUpper, d.dom.yUpper)
+}
+
+func (d domainImage) At(x, y int) color.Color {
+	return d.img.At(x, y)
+}
+
+// Runs the named extractor on every tile of a columns x rows grid and
+// keeps the most prominent color of each. opts.Count is ignored, tiles
+// are extracted opts.Goroutines at a time.
+func ExtractSpatialPalette(img image.Image, algorithm string, columns, rows int, opts Options) (SpatialPalette, error) {
+	b := img.Bounds()
+	if columns < 1 || rows < 1 || columns > b.Dx() || rows > b.Dy() {
+		return SpatialPalette{}, errors.New("In ExtractSpatialPalette: Grid must be at least 1x1 and at most one tile per pixel.")
+	}
+	if _, err := GetExtractor(algorithm); err != nil {
+		return SpatialPalette{}, err
+	}
+	workers := opts.Goroutines
+	if workers < 1 {
+		workers = 1
+	}
+	opts.Count = 1
+	opts.Goroutines = 1
+
+	domains := CreateGridDomains(img, columns, rows)
+	colors := make([]string, len(domains))
+	errs := make([]error, len(domains))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				tile := domainImage{img, domains[j]}
+				palette, err := Extract(algorithm, tile, opts)
+				switch {
+				case err != nil:
+					errs[j] = err
+				case len(palette) == 0:
+					colors[j] = NRGBAToHex(averageColor(tile))
+				default:
+					colors[j] = palette[0].ColString
+				}
+			}
+		}()
+	}
+	for j := range domains {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return SpatialPalette{}, err
+		}
+	}
+
+	matrix := make([][]string, rows)
+	for row := range matrix {
+		matrix[row] = colors[row*columns : (row+1)*columns]
+	}
+	return NewSpatialPalette(matrix)
+}
+
+// Fills in the gradients, CSS and image of a color matrix.
+func NewSpatialPalette(colors [][]string) (SpatialPalette, error) {
+	p := SpatialPalette{Rows: len(colors), Colors: colors}
+	if p.Rows > 0 {
+		p.Columns = len(colors[0])
+	}
+
+	layers := make([]string, p.Rows)
+	p.Gradients = make([]string, p.Rows)
+	for row, line := range colors {
+		if len(line) != p.Columns {
+			return SpatialPalette{}, errors.New("In NewSpatialPalette: Rows have different lengths.")
+		}
+		p.Gradients[row] = rowGradient(line)
+		// Layers are positioned by their top edge, 0% to 100% of the
+		// space left over.
+		top := 0.0
+		if p.Rows > 1 {
+			top = float64(row) * 100 / float64(p.Rows-1)
+		}
+		layers[row] = fmt.Sprintf("%s 0 %.4g%% / 100%% %.4g%% no-repeat", p.Gradients[row], top, 100/float64(p.Rows))
+	}
+	p.CSS = strings.Join(layers, ", ")
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, p.Image()); err != nil {
+		return SpatialPalette{}, err
+	}
+	p.DataURI = "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+	return p, nil
+}
+
+// linear-gradient through the colors of one row, each at the center of
+// its tile.
+func rowGradient(line []string) string {
+	if len(line) == 1 {
+		return fmt.Sprintf("linear-gradient(to right, %s, %s)", line[0], line[0])
+	}
+	stops := make([]string, len(line))
+	for i, hex := range line {
+		stops[i] = fmt.Sprintf("%s %.4g%%", hex, (float64(i)+0.5)*100/float64(len(line)))
+	}
+	return "linear-gradient(to right, " + strings.Join(stops, ", ") + ")"
+}
+
+// The matrix with one pixel per tile.
+func (p SpatialPalette) Image() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, p.Columns, p.Rows))
+	for row, line := range p.Colors {
+		for col, hex := range line {
+			img.SetNRGBA(col, row, HexToNRGBA(hex))
+		}
+	}
+	return img
+}
+
+// Mean of the opaque pixels of img, transparent if it has none.
+func averageColor(img image.Image) color.NRGBA {
+	var r, g, b, n uint64
+	eachPixel(img, func(c color.NRGBA) {
+		if c.A == 0 {
+			return
+		}
+		r += uint64(c.R)
+		g += uint64(c.G)
+		b += uint64(c.B)
+		n++
+	})
+	if n == 0 {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{uint8(r / n), uint8(g / n), uint8(b / n), 255}
+}
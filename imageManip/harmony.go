@@ -0,0 +1,138 @@
+package imageManip
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Harmonies SuggestHarmony knows, with the hue rotations (in degrees)
+// that produce them from the base color.
+const (
+	HARMONY_COMPLEMENTARY       = "complementary"
+	HARMONY_ANALOGOUS           = "analogous"
+	HARMONY_TRIADIC             = "triadic"
+	HARMONY_TETRADIC            = "tetradic"
+	HARMONY_SPLIT_COMPLEMENTARY = "split-complementary"
+)
+
+var HARMONIES = []string{
+	HARMONY_COMPLEMENTARY,
+	HARMONY_ANALOGOUS,
+	HARMONY_TRIADIC,
+	HARMONY_TETRADIC,
+	HARMONY_SPLIT_COMPLEMENTARY,
+}
+
+var harmonyRotations = map[string][]float64{
+	HARMONY_COMPLEMENTARY:       {180},
+	HARMONY_ANALOGOUS:           {-30, 30},
+	HARMONY_TRIADIC:             {120, 240},
+	HARMONY_TETRADIC:            {90, 180, 270},
+	HARMONY_SPLIT_COMPLEMENTARY: {150, 210},
+}
+
+// Color spaces the hue is rotated in.
+const (
+	HARMONY_SPACE_OKLCH = "oklch"
+	HARMONY_SPACE_HSL   = "hsl"
+)
+
+// Suggestions closer than this (OKLab ΔE) to an image color are replaced
+// by it.
+const HARMONY_SNAP_DISTANCE = 0.08
+
+type Harmony struct {
+	Kind   string         `json:"kind"`
+	Base   string         `json:"base"`
+	Colors []HarmonyColor `json:"colors"`
+}
+
+type HarmonyColor struct {
+	Color color.NRGBA `json:"-"`
+	Hex   string      `json:"color"`
+	// Degrees the base hue was rotated by.
+	Rotation float64 `json:"rotation"`
+	// Whether Color is an image color rather than the rotated one, and
+	// how far it is from the rotated one.
+	Snapped bool    `json:"snapped"`
+	DeltaE  float64 `json:"deltaE,omitempty"`
+}
+
+// Every harmony of base, see SuggestHarmony.
+func SuggestHarmonies(base color.NRGBA, imageColors []ColAndFreq, space string, snapDistance float64) ([]Harmony, error) {
+	ret := make([]Harmony, 0, len(HARMONIES))
+	for _, kind := range HARMONIES {
+		h, err := SuggestHarmony(base, kind, imageColors, space, snapDistance)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, h)
+	}
+	return ret, nil
+}
+
+// Rotates the hue of base in space (HARMONY_SPACE_OKLCH or _HSL) to make
+// the colors of the harmony kind. Each one is swapped for the nearest of
+// imageColors when that is within snapDistance, so suggestions come from
+// the image where it has something fitting. An image color is used once
+// per harmony and never when it is base itself.
+func SuggestHarmony(
+	base color.NRGBA,
+	kind string,
+	imageColors []ColAndFreq,
+	space string,
+	snapDistance float64,
+) (Harmony, error) {
+	rotations, ok := harmonyRotations[kind]
+	if !ok {
+		return Harmony{}, fmt.Errorf("Unknown harmony %q. Available: %v.", kind, HARMONIES)
+	}
+	if space != HARMONY_SPACE_OKLCH && space != HARMONY_SPACE_HSL {
+		return Harmony{}, fmt.Errorf("Unknown color space %q, use %q or %q.", space, HARMONY_SPACE_OKLCH, HARMONY_SPACE_HSL)
+	}
+
+	candidates := make([]color.NRGBA, 0, len(imageColors))
+	for _, c := range imageColors {
+		col := HexToNRGBA(c.ColString)
+		if col != base {
+			candidates = append(candidates, col)
+		}
+	}
+	used := make(map[int]bool)
+
+	h := Harmony{Kind: kind, Base: NRGBAToHex(base)}
+	for _, rotation := range rotations {
+		rotated := rotateHue(base, rotation, space)
+		hc := HarmonyColor{Color: rotated, Rotation: rotation}
+
+		nearest, nearestDist := -1, math.Inf(1)
+		for i, c := range candidates {
+			if used[i] {
+				continue
+			}
+			if d := DeltaEOK(rotated, c); d < nearestDist {
+				nearest, nearestDist = i, d
+			}
+		}
+		if nearest >= 0 && nearestDist <= snapDistance {
+			used[nearest] = true
+			hc.Color = candidates[nearest]
+			hc.Snapped = true
+			hc.DeltaE = nearestDist
+		}
+		hc.Hex = NRGBAToHex(hc.Color)
+		h.Colors = append(h.Colors, hc)
+	}
+	return h, nil
+}
+
+func rotateHue(c color.NRGBA, degrees float64, space string) color.NRGBA {
+	if space == HARMONY_SPACE_HSL {
+		h, s, l := NRGBAToHSL(c)
+		return HSLToNRGBA(h+degrees, s, l)
+	}
+	lch := NRGBAToOKLCH(c)
+	lch.H = math.Mod(lch.H+degrees+360, 360)
+	return OKLCHToNRGBA(lch)
+}
@@ -0,0 +1,241 @@
+package imageManip
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"math"
+)
+
+// Minimal ICC support: RGB matrix/TRC profiles, which covers sRGB,
+// Display P3, Adobe RGB and most camera and screen profiles. LUT based
+// profiles are left alone.
+
+// sRGB primaries adapted to the D50 profile connection space (Bradford).
+var srgbD50 = [3][3]float64{
+	{0.4360747, 0.3850649, 0.1430804},
+	{0.2225045, 0.7168786, 0.0606169},
+	{0.0139322, 0.0971045, 0.7141733},
+}
+
+// Inverse of srgbD50.
+var srgbD50Inverse = [3][3]float64{
+	{3.1338561, -1.6168667, -0.4906146},
+	{-0.9787684, 1.9161415, 0.0334540},
+	{0.0719453, -0.2289914, 1.4052427},
+}
+
+// Tone response curve, maps an encoded channel value in [0, 1] to linear.
+type toneCurve func(float64) float64
+
+type iccProfile struct {
+	// Columns are the XYZ (D50) values of the red, green and blue primaries.
+	toXYZ [3][3]float64
+	trc   [3]toneCurve
+}
+
+func parseICCProfile(data []byte) (*iccProfile, error) {
+	if len(data) < 132 {
+		return nil, errors.New("In parseICCProfile: profile too short.")
+	}
+	if string(data[16:20]) != "RGB " {
+		return nil, errors.New("In parseICCProfile: not an RGB profile.")
+	}
+
+	tags := make(map[string][]byte)
+	tagCount := int(binary.BigEndian.Uint32(data[128:]))
+	for i := 0; i < tagCount; i++ {
+		entry := 132 + i*12
+		if entry+12 > len(data) {
+			break
+		}
+		sig := string(data[entry : entry+4])
+		offset := int(binary.BigEndian.Uint32(data[entry+4:]))
+		size := int(binary.BigEndian.Uint32(data[entry+8:]))
+		if offset < 0 || size < 0 || offset+size > len(data) {
+			continue
+		}
+		tags[sig] = data[offset : offset+size]
+	}
+
+	p := &iccProfile{}
+	for i, sig := range []string{"rXYZ", "gXYZ", "bXYZ"} {
+		xyz, err := parseXYZTag(tags[sig])
+		if err != nil {
+			return nil, err
+		}
+		for row := 0; row < 3; row++ {
+			p.toXYZ[row][i] = xyz[row]
+		}
+	}
+	for i, sig := range []string{"rTRC", "gTRC", "bTRC"} {
+		curve, err := parseCurveTag(tags[sig])
+		if err != nil {
+			return nil, err
+		}
+		p.trc[i] = curve
+	}
+	return p, nil
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+func parseXYZTag(tag []byte) ([3]float64, error) {
+	if len(tag) < 20 || string(tag[:4]) != "XYZ " {
+		return [3]float64{}, errors.New("In parseICCProfile: missing or invalid colorant tag.")
+	}
+	return [3]float64{s15Fixed16(tag[8:]), s15Fixed16(tag[12:]), s15Fixed16(tag[16:])}, nil
+}
+
+func parseCurveTag(tag []byte) (toneCurve, error) {
+	invalid := errors.New("In parseICCProfile: missing or invalid tone curve.")
+	if len(tag) < 12 {
+		return nil, invalid
+	}
+
+	switch string(tag[:4]) {
+	case "curv":
+		count := int(binary.BigEndian.Uint32(tag[8:]))
+		if len(tag) < 12+2*count {
+			return nil, invalid
+		}
+		switch count {
+		case 0:
+			return func(v float64) float64 { return v }, nil
+		case 1:
+			gamma := float64(binary.BigEndian.Uint16(tag[12:])) / 256
+			return func(v float64) float64 { return math.Pow(v, gamma) }, nil
+		}
+		table := make([]float64, count)
+		for i := range table {
+			table[i] = float64(binary.BigEndian.Uint16(tag[12+2*i:])) / 65535
+		}
+		return func(v float64) float64 {
+			pos := clamp01(v) * float64(count-1)
+			i := int(pos)
+			if i >= count-1 {
+				return table[count-1]
+			}
+			frac := pos - float64(i)
+			return table[i]*(1-frac) + table[i+1]*frac
+		}, nil
+	case "para":
+		funcType := int(binary.BigEndian.Uint16(tag[8:]))
+		nParams := []int{1, 3, 4, 5, 7}
+		if funcType >= len(nParams) || len(tag) < 12+4*nParams[funcType] {
+			return nil, invalid
+		}
+		// g, a, b, c, d, e, f as in the ICC spec.
+		var prm [7]float64
+		for i := 0; i < nParams[funcType]; i++ {
+			prm[i] = s15Fixed16(tag[12+4*i:])
+		}
+		g, a, b, c, d, e, f := prm[0], prm[1], prm[2], prm[3], prm[4], prm[5], prm[6]
+		return func(x float64) float64 {
+			switch funcType {
+			case 0:
+				return math.Pow(x, g)
+			case 1:
+				if x >= -b/a {
+					return math.Pow(a*x+b, g)
+				}
+				return 0
+			case 2:
+				if x >= -b/a {
+					return math.Pow(a*x+b, g) + c
+				}
+				return c
+			case 3:
+				if x >= d {
+					return math.Pow(a*x+b, g)
+				}
+				return c * x
+			default:
+				if x >= d {
+					return math.Pow(a*x+b, g) + e
+				}
+				return c*x + f
+			}
+		}, nil
+	}
+	return nil, invalid
+}
+
+// Reports whether the profile is (close enough to) sRGB, in which case
+// converting would only add rounding errors.
+func (p *iccProfile) isSRGB() bool {
+	const eps = 0.002
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			if math.Abs(p.toXYZ[row][col]-srgbD50[row][col]) > eps {
+				return false
+			}
+		}
+	}
+	for _, curve := range p.trc {
+		for _, v := range []float64{0.1, 0.3, 0.5, 0.7, 0.9} {
+			if math.Abs(curve(v)-srgbToLinear(v)) > 1.0/255 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func mulMatrix(a, b [3][3]float64) (ret [3][3]float64) {
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				ret[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return
+}
+
+// Converts img from the profile's color space to sRGB.
+func (p *iccProfile) toSRGB(img image.Image) image.Image {
+	if p.isSRGB() {
+		return img
+	}
+
+	m := mulMatrix(srgbD50Inverse, p.toXYZ)
+
+	var linear [3][256]float64
+	for c := 0; c < 3; c++ {
+		for v := 0; v < 256; v++ {
+			linear[c][v] = p.trc[c](float64(v) / 255)
+		}
+	}
+	const encodeSize = 4096
+	var encode [encodeSize + 1]uint8
+	for i := range encode {
+		encode[i] = to8Bit(linearToSrgb(float64(i) / encodeSize))
+	}
+
+	dst := toNRGBA(img)
+	for i := 0; i+3 < len(dst.Pix); i += 4 {
+		r := linear[0][dst.Pix[i]]
+		g := linear[1][dst.Pix[i+1]]
+		b := linear[2][dst.Pix[i+2]]
+		for c := 0; c < 3; c++ {
+			v := clamp01(m[c][0]*r + m[c][1]*g + m[c][2]*b)
+			dst.Pix[i+c] = encode[int(v*encodeSize+0.5)]
+		}
+	}
+	return dst
+}
+
+// Applies EXIF orientation and converts tagged images to sRGB, so the
+// palette matches what an image viewer shows.
+func normalizeImage(img image.Image, data []byte) image.Image {
+	meta := readMetadata(data)
+	if meta.icc != nil {
+		if p, err := parseICCProfile(meta.icc); err == nil {
+			img = p.toSRGB(img)
+		}
+	}
+	return applyOrientation(img, meta.orientation)
+}
@@ -42,17 +42,23 @@ func ParseArgs() (s string, e string, err error) {
 }
 
 func CreateColorFrequencyMap(img image.Image) map[string]int {
+	return CreateWeightedColorFrequencyMap(img, nil)
+}
+
+// Like CreateColorFrequencyMap, but each pixel adds its weight times
+// WEIGHT_SCALE. nil weights count every pixel once.
+func CreateWeightedColorFrequencyMap(img image.Image, weights *WeightMap) map[string]int {
 	colFreqMap := make(map[string]int)
 	domains := CreateDomains(img)
 	allMaps := make([]map[string]int, CORES_TO_USE)
-	fmt.Println("domains:", domains)
+	debugln("domains:", domains)
 
 	var wg sync.WaitGroup
 	wg.Add(CORES_TO_USE)
 
 	for i, dom := range domains {
 		newMap := make(map[string]int)
-		go CountColors(img, dom, newMap, &wg)
+		go CountColors(img, dom, weights, newMap, &wg)
 		allMaps[i] = newMap
 	}
 	wg.Wait()
@@ -64,25 +70,25 @@ func CreateColorFrequencyMap(img image.Image) map[string]int {
 // Split the bounds of the image into equal parts based on the
 // number of cores being utilized.
 func CreateDomains(img image.Image) (domains []Domain) {
-	xChunk := img.Bounds().Max.X / CORES_TO_USE
-	maxY := img.Bounds().Max.Y
+	b := img.Bounds()
+	xChunk := b.Dx() / CORES_TO_USE
 
 	for i := 1; i < CORES_TO_USE; i++ {
 		newDom := Domain{
-			xUpper: xChunk * i,
-			yUpper: maxY,
-			xLower: xChunk*i - xChunk,
-			yLower: 0,
+			xUpper: b.Min.X + xChunk*i,
+			yUpper: b.Max.Y,
+			xLower: b.Min.X + xChunk*i - xChunk,
+			yLower: b.Min.Y,
 		}
 		domains = append(domains, newDom)
 	}
 
 	// Last domain picks up remainder pixels.
 	lastDom := Domain{
-		xUpper: img.Bounds().Max.X,
-		yUpper: maxY,
-		xLower: (xChunk * (CORES_TO_USE - 1)),
-		yLower: 0,
+		xUpper: b.Max.X,
+		yUpper: b.Max.Y,
+		xLower: b.Min.X + xChunk*(CORES_TO_USE-1),
+		yLower: b.Min.Y,
 	}
 	domains = append(domains, lastDom)
 
@@ -90,14 +96,20 @@ func CreateDomains(img image.Image) (domains []Domain) {
 }
 
 // Add to the map for
-func CountColors(img image.Image, dom Domain,
+// With weights, each pixel adds its weight (see WeightMap.count) instead
+// of 1.
+func CountColors(img image.Image, dom Domain, weights *WeightMap,
 	newMap map[string]int, wg *sync.WaitGroup) map[string]int {
 	defer wg.Done()
 
 	for i := dom.xLower; i < dom.xUpper; i++ {
 		for j := dom.yLower; j < dom.yUpper; j++ {
 			ColString := ColorToString(img.At(i, j))
-			newMap[ColString]++
+			if weights == nil {
+				newMap[ColString]++
+			} else if w := weights.count(i, j); w > 0 {
+				newMap[ColString] += w
+			}
 		}
 	}
 	return newMap
@@ -134,12 +146,13 @@ func mostProminentColor(colFreqMap map[string]int) ColAndFreq {
 	return max
 }
 
-// return an array of the n most prominent colors
+// return an array of the n most prominent colors, fewer if the map runs
+// out.
 func GetMostProminentColors(n int, colFreqMap map[string]int) []ColAndFreq {
-	ret := make([]ColAndFreq, n)
-	for i := 0; i < n; i++ {
+	ret := make([]ColAndFreq, 0, n)
+	for i := 0; i < n && len(colFreqMap) > 0; i++ {
 		cur := mostProminentColor(colFreqMap)
-		ret[i] = cur
+		ret = append(ret, cur)
 		delete(colFreqMap, cur.ColString)
 	}
 	return ret
@@ -205,16 +218,16 @@ func mostProminentColorImproved(colFreqMap map[string]int, tolerance float64) Co
 
 // Takes a colFreqMap and applies a tolerance value to get the specified
 // number of 'most prominent colors'. Each of these prominent colors is
-// a weighted average of all the colors similar to it.
+// a weighted average of all the colors similar to it. Images with fewer
+// colors get a shorter palette.
 func getMostProminentColorsImproved(
 	numberOfColors int,
 	colFreqMap map[string]int,
 	tolerance float64,
 ) []ColAndFreq {
-	ret := make([]ColAndFreq, numberOfColors)
-	for i := 0; i < numberOfColors; i++ {
-		cur := mostProminentColorImproved(colFreqMap, tolerance)
-		ret[i] = cur
+	ret := make([]ColAndFreq, 0, numberOfColors)
+	for i := 0; i < numberOfColors && len(colFreqMap) > 0; i++ {
+		ret = append(ret, mostProminentColorImproved(colFreqMap, tolerance))
 	}
 	return ret
 }
@@ -236,7 +249,7 @@ func SimplifyColFreqMap(
 	tolerance float64,
 	colFreqMap map[string]int,
 ) map[string]int {
-	fmt.Println("SimplifyColMap was called.")
+	debugln("SimplifyColMap was called.")
 	// the keys of the map act as representatives of the color group
 	colorGroups := make(map[string][]ColAndFreq)
 
@@ -266,20 +279,34 @@ func SimplifyColFreqMap(
 			colorGroups[k] = []ColAndFreq{newMember}
 		}
 	}
-	fmt.Printf("Loops exited. %d color groups created.\n", len(colorGroups))
+	debugf("Loops exited. %d color groups created.\n", len(colorGroups))
 	// merge color groups into a return color Frequency map.
 	return mergeColorGroups(colorGroups)
 }
 
-// This removes all elements in colFreqMap that are below the
-// frequency threshold.
-func flenseColFreqMap(colFreqMap map[string]int) {
-	threshold := 100
+// Share of the pixels a color needs to survive flenseColFreqMap when no
+// other share is given.
+const MIN_COLOR_SHARE = 0.0001
+
+// This returns the elements in colFreqMap that have at least minShare of
+// the pixels. 0 uses MIN_COLOR_SHARE.
+func flenseColFreqMap(colFreqMap map[string]int, minShare float64) map[string]int {
+	if minShare <= 0 {
+		minShare = MIN_COLOR_SHARE
+	}
+	total := 0
+	for _, val := range colFreqMap {
+		total += val
+	}
+	threshold := minShare * float64(total)
+	ret := make(map[string]int)
 	for key, val := range colFreqMap {
-		if val < threshold {
-			delete(colFreqMap, key)
+		if float64(val) >= threshold {
+			ret[key] = val
 		}
 	}
+	debugf("\nLength of colFreqMap before flensing: %d, after: %d\n", len(colFreqMap), len(ret))
+	return ret
 }
 
 // create groups of similar colors according to some distance tolerance value
@@ -288,11 +315,7 @@ func SimplifyColFreqMapConcurrent(
 	colFreqMap map[string]int,
 	numberOfGoroutines int,
 ) map[string]int {
-	fmt.Println("SimplifyColMapConcurrent was called.")
-
-	fmt.Printf("\nLength of colFreqMap before flensing: %d\n", len(colFreqMap))
-	flenseColFreqMap(colFreqMap)
-	fmt.Printf("\nLength of colFreqMap after flensing: %d\n", len(colFreqMap))
+	debugln("SimplifyColMapConcurrent was called.")
 
 	// Split map into sections to be handled concurrently.
 	// Each subMap maps a color value to its frequency in the image.
@@ -301,10 +324,12 @@ func SimplifyColFreqMapConcurrent(
 
 	// Comparing the size of the submaps to the main colFreqMap.
 	for i, s := range subMaps {
-		fmt.Printf("Length of subMap %d: %d\n", i, len(s))
+		debugf("Length of subMap %d: %d\n", i, len(s))
 	}
 
-	debugSubMaps(subMaps)
+	if Debug {
+		debugSubMaps(subMaps)
+	}
 
 	// each element in colorGroupsArray holds the corresponding colorGroups
 	// for each subMap.
@@ -330,7 +355,7 @@ func SimplifyColFreqMapConcurrent(
 		}
 	}
 
-	fmt.Printf(
+	debugf(
 		"subMaps merged. %d color groups created.\n",
 		len(colorGroups),
 	)
@@ -338,7 +363,7 @@ func SimplifyColFreqMapConcurrent(
 	// merge color groups into a return color Frequency map. (map[string]int).
 	retMap := mergeColorGroups(colorGroups)
 
-	fmt.Printf(
+	debugf(
 		"Color groups merged. %d colors in return map.\n",
 		len(retMap),
 	)
@@ -381,6 +406,10 @@ func getColorGroups(
 	// the keys of the colorGroups map act as representatives of the color group
 	// The values of the keys of colorGroups are arrays of ColAndFreq structs.
 	colorGroups := colorGroupsArray[index]
+	// The reps and their parsed colors, so each color string is only
+	// parsed once.
+	reps := make([]string, 0)
+	repArrs := make([][3]float64, 0)
 
 	for k, v := range colFreqMap {
 		//fmt.Println("Outer loop.")
@@ -389,12 +418,13 @@ func getColorGroups(
 			ColString: k,
 			Frequency: v,
 		}
+		kArr := ColStringToArr(k)
 		// Observation: The higher the tolerance, the faster the program runs.
 		// Why is this? I do not know.
-		for rep, _ := range colorGroups {
+		for i, repArr := range repArrs {
 			// if a color fits into a color group add it to the array.
-			if distance(ColStringToArr(rep), ColStringToArr(k)) < tolerance {
-				colorGroups[rep] = append(colorGroups[rep], newMember)
+			if distance(repArr, kArr) < tolerance {
+				colorGroups[reps[i]] = append(colorGroups[reps[i]], newMember)
 				groupFound = true
 				break
 			}
@@ -404,10 +434,12 @@ func getColorGroups(
 		if !groupFound {
 			//fmt.Println("outer if entered.")
 			colorGroups[k] = []ColAndFreq{newMember}
+			reps = append(reps, k)
+			repArrs = append(repArrs, kArr)
 		}
 	}
 
-	fmt.Printf(
+	debugf(
 		"%d color groups created for subMap %d.\n",
 		len(colorGroups),
 		index,
@@ -422,19 +454,19 @@ func countEmptyStrings(colorGroups map[string][]ColAndFreq) {
 		for _, col := range v {
 			if col.ColString == "" {
 				count++
-				fmt.Println(col)
+				debugln(col)
 				emptyArrs[k] = v
 			}
 		}
 	}
-	fmt.Printf("%d empty elements found.\n", count)
-	fmt.Println(emptyArrs)
+	debugf("%d empty elements found.\n", count)
+	debugln(emptyArrs)
 }
 
 func mergeColorGroups(
 	colorGroups map[string][]ColAndFreq,
 ) map[string]int {
-	fmt.Println("mergeColorGroups called.")
+	debugln("mergeColorGroups called.")
 	merged := make(map[string]int)
 	for _, v := range colorGroups {
 		retVal := mergeColAndFreqArr(v)
@@ -454,7 +486,7 @@ func mergeColAndFreqArr(cols []ColAndFreq) ColAndFreq {
 		// color sums.
 		//fmt.Printf("\"%s\"\n", col.ColString)
 		if col.ColString == "" {
-			fmt.Println(col)
+			debugln(col)
 		}
 		//
 		colArr := ColStringToArr(col.ColString)
@@ -487,14 +519,14 @@ func sq(a float64) float64 {
 	return a * a
 }
 
+// rgba in format "r, g, b, a" or "(r, g, b, a)"
 func rgbaToHex(rgba string) string {
-	temp := strings.Split(rgba, " ")[:3]
-	lt := []int{len(temp[0]), len(temp[1]), len(temp[2])}
+	rgba = strings.Trim(rgba, "()")
+	temp := strings.Split(rgba, ", ")[:3]
 
-	r, g, b := temp[0][1:lt[0]-1], temp[1][:lt[1]-1], temp[2][:lt[2]-1]
-	ri, _ := strconv.Atoi(r)
-	gi, _ := strconv.Atoi(g)
-	bi, _ := strconv.Atoi(b)
+	ri, _ := strconv.Atoi(temp[0])
+	gi, _ := strconv.Atoi(temp[1])
+	bi, _ := strconv.Atoi(temp[2])
 
 	rh := strconv.FormatInt(int64(ri), 16)
 	if len(rh) == 1 {
@@ -547,8 +579,8 @@ func removeContents(dir string) error {
 func debugSubMaps(subMaps []map[string]int) {
 	err := removeContents("subMaps")
 	if err != nil {
-		fmt.Printf("\nSomething went wrong removing contents of subMaps\n")
-		fmt.Println(err)
+		debugf("\nSomething went wrong removing contents of subMaps\n")
+		debugln(err)
 		return
 	}
 
@@ -556,8 +588,8 @@ func debugSubMaps(subMaps []map[string]int) {
 		filePath := fmt.Sprintf("subMaps/subMap%d", i)
 		f, err := os.Create(filePath)
 		if err != nil {
-			fmt.Printf("\nError creating %s\n", filePath)
-			fmt.Println(err)
+			debugf("\nError creating %s\n", filePath)
+			debugln(err)
 			return
 		}
 
@@ -569,8 +601,8 @@ func debugSubMaps(subMaps []map[string]int) {
 
 		_, err = f.Write(data)
 		if err != nil {
-			fmt.Printf("\nError writing to %s\n", filePath)
-			fmt.Println(err)
+			debugf("\nError writing to %s\n", filePath)
+			debugln(err)
 			return
 		}
 
@@ -595,10 +627,50 @@ func ExtractPaletteConcurrent(
 	tolerance float64,
 ) []ColAndFreq {
 	colorFrequencyMap := CreateColorFrequencyMap(uploaded)
-	colorFrequencyMap = SimplifyColFreqMapConcurrent(
-		tolerance,
-		colorFrequencyMap,
-		numberOfGoroutines,
-	)
-	return rgbaToHexArr(getMostProminentColorsImproved(colsToExtract, colorFrequencyMap, tolerance))
+	return paletteFromColFreqMap(colorFrequencyMap, colsToExtract, numberOfGoroutines, tolerance, 0)
+}
+
+// The part of ExtractPaletteConcurrent after the colors have been counted.
+// Colors with less than minShare of the pixels are dropped before merging,
+// 0 uses MIN_COLOR_SHARE. On noisy images hardly any exact color repeats,
+// so when that leaves fewer than colsToExtract colors all colors are
+// merged and minShare is applied to the merged colors instead.
+func paletteFromColFreqMap(
+	colorFrequencyMap map[string]int,
+	colsToExtract int,
+	numberOfGoroutines int,
+	tolerance float64,
+	minShare float64,
+) []ColAndFreq {
+	flensed := flenseColFreqMap(colorFrequencyMap, minShare)
+	merged := SimplifyColFreqMapConcurrent(tolerance, flensed, numberOfGoroutines)
+	if len(merged) < colsToExtract && len(flensed) < len(colorFrequencyMap) {
+		merged = SimplifyColFreqMapConcurrent(tolerance, colorFrequencyMap, numberOfGoroutines)
+		merged = flenseColFreqMap(merged, minShare)
+	}
+	return rgbaToHexArr(getMostProminentColorsImproved(colsToExtract, merged, tolerance))
+}
+
+// The colors of palette, in order, that are at least maxDistance away from
+// all of the hex codes.
+func ExcludeNearColors(palette []ColAndFreq, hexCodes []string, maxDistance float64) []ColAndFreq {
+	excluded := make([][3]float64, len(hexCodes))
+	for i, hex := range hexCodes {
+		c := HexToNRGBA(hex)
+		excluded[i] = [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+	}
+
+	ret := make([]ColAndFreq, 0, len(palette))
+outer:
+	for _, col := range palette {
+		c := HexToNRGBA(col.ColString)
+		colArr := [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+		for _, ex := range excluded {
+			if distance(colArr, ex) < maxDistance {
+				continue outer
+			}
+		}
+		ret = append(ret, col)
+	}
+	return ret
 }
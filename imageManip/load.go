@@ -0,0 +1,212 @@
+package imageManip
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// File extensions of the formats registered with the image package.
+var SUPPORTED_EXTENSIONS = []string{
+	"png", "jpg", "jpeg", "gif", "bmp", "tif", "tiff", "webp",
+}
+
+// Decoded frames of an image. Still images have a single frame.
+// Frames of animated GIFs are composited, so every frame is the full
+// picture as it is shown at that point of the animation.
+type Frames struct {
+	Images []image.Image
+	// Delay of each frame in 100ths of a second. Zero for still images.
+	Delays []int
+}
+
+func (f Frames) Animated() bool {
+	return len(f.Images) > 1
+}
+
+// Decodes an image from src, which can be a file path (string), raw
+// encoded bytes ([]byte) or an io.Reader. Only the first frame of
+// animated images is returned.
+func LoadImage(src interface{}) (image.Image, error) {
+	data, err := readSource(src)
+	if err != nil {
+		return nil, err
+	}
+	return decodeImage(data)
+}
+
+// Like LoadImage, but returns every frame of animated GIFs.
+func LoadFrames(src interface{}) (Frames, error) {
+	data, err := readSource(src)
+	if err != nil {
+		return Frames{}, err
+	}
+
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == nil && format == "gif" {
+		return decodeGIFFrames(data)
+	}
+
+	img, err := decodeImage(data)
+	if err != nil {
+		return Frames{}, err
+	}
+	return Frames{Images: []image.Image{img}, Delays: []int{0}}, nil
+}
+
+// Width and height of the encoded image in data, read from its header
+// without decoding the pixels.
+func ImageSize(data []byte) (int, int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return 0, 0, formatError(data)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+func readSource(src interface{}) ([]byte, error) {
+	switch src := src.(type) {
+	case string:
+		return ioutil.ReadFile(src)
+	case []byte:
+		return src, nil
+	case io.Reader:
+		return ioutil.ReadAll(src)
+	default:
+		return nil, fmt.Errorf("In LoadImage: unsupported source type %T.", src)
+	}
+}
+
+func decodeImage(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return nil, formatError(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return normalizeImage(img, data), nil
+}
+
+// Names formats we recognize but can't decode, so the user isn't left
+// with a bare "unknown format".
+func formatError(data []byte) error {
+	name := ""
+	switch {
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		switch string(data[8:12]) {
+		case "heic", "heix", "hevc", "mif1", "msf1":
+			name = "HEIC/HEIF"
+		case "avif", "avis":
+			name = "AVIF"
+		}
+	case bytes.HasPrefix(data, []byte{0xff, 0x0a}),
+		bytes.HasPrefix(data, []byte("\x00\x00\x00\x0cJXL ")):
+		name = "JPEG XL"
+	case bytes.HasPrefix(data, []byte("<svg")), bytes.HasPrefix(data, []byte("<?xml")):
+		name = "SVG"
+	case bytes.HasPrefix(data, []byte("8BPS")):
+		name = "PSD"
+	}
+
+	supported := strings.Join(SUPPORTED_EXTENSIONS, ", ")
+	if name != "" {
+		return fmt.Errorf("%s images are not supported. Supported formats: %s.", name, supported)
+	}
+	return fmt.Errorf("Unrecognized image format. Supported formats: %s.", supported)
+}
+
+// Decodes all frames of a GIF and applies each frame's disposal method.
+func decodeGIFFrames(data []byte) (Frames, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return Frames{}, err
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	canvas := image.NewRGBA(bounds)
+	frames := Frames{
+		Images: make([]image.Image, len(g.Image)),
+		Delays: make([]int, len(g.Image)),
+	}
+
+	for i, frame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			draw.Draw(previous, bounds, canvas, bounds.Min, draw.Src)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		composited := image.NewRGBA(bounds)
+		draw.Draw(composited, bounds, canvas, bounds.Min, draw.Src)
+		frames.Images[i] = composited
+		if i < len(g.Delay) {
+			frames.Delays[i] = g.Delay[i]
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames, nil
+}
+
+// Turns clipboard or drop text into something LoadImage accepts.
+// Handles plain paths, file:// URIs (the first one of a text/uri-list)
+// and base64 data: URIs.
+func ImageSourceFromText(text string) (interface{}, error) {
+	var line string
+	for _, l := range strings.Split(text, "\n") {
+		l = strings.TrimSpace(l)
+		// Comments are allowed in text/uri-list.
+		if l != "" && !strings.HasPrefix(l, "#") {
+			line = l
+			break
+		}
+	}
+	if line == "" {
+		return nil, errors.New("In ImageSourceFromText: no path in text.")
+	}
+
+	switch {
+	case strings.HasPrefix(line, "data:"):
+		comma := strings.Index(line, ",")
+		if comma < 0 || !strings.HasSuffix(line[:comma], ";base64") {
+			return nil, errors.New("In ImageSourceFromText: only base64 data URIs are supported.")
+		}
+		return base64.StdEncoding.DecodeString(line[comma+1:])
+	case strings.HasPrefix(line, "file://"):
+		u, err := url.Parse(line)
+		if err != nil {
+			return nil, err
+		}
+		return u.Path, nil
+	default:
+		return line, nil
+	}
+}
@@ -0,0 +1,227 @@
+package imageManip
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"io/ioutil"
+	"sort"
+)
+
+// Metadata image.Decode ignores but that changes how an image should look.
+type imageMetadata struct {
+	// EXIF orientation, 1 to 8. 1 (or 0 if missing) means no transform.
+	orientation int
+	// Raw ICC profile, nil if the image has none.
+	icc []byte
+}
+
+// Reads EXIF orientation and the ICC profile from the container of data.
+// Unknown containers and broken metadata give an empty result, the image
+// is then shown as decoded.
+func readMetadata(data []byte) imageMetadata {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		return jpegMetadata(data)
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return pngMetadata(data)
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return webpMetadata(data)
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		orientation, icc := readTIFFTags(data)
+		return imageMetadata{orientation: orientation, icc: icc}
+	}
+	return imageMetadata{}
+}
+
+// Walks the JPEG marker segments up to the start of scan. EXIF is in
+// APP1, the ICC profile is split over numbered APP2 segments.
+func jpegMetadata(data []byte) (meta imageMetadata) {
+	iccChunks := make(map[int][]byte)
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xff {
+			break
+		}
+		marker := data[pos+1]
+		// Start of scan, no more metadata after this.
+		if marker == 0xda {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			break
+		}
+		segment := data[pos+4 : pos+2+length]
+
+		switch {
+		case marker == 0xe1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")):
+			meta.orientation, _ = readTIFFTags(segment[6:])
+		case marker == 0xe2 && bytes.HasPrefix(segment, []byte("ICC_PROFILE\x00")) && len(segment) > 14:
+			iccChunks[int(segment[12])] = segment[14:]
+		}
+		pos += 2 + length
+	}
+
+	if len(iccChunks) > 0 {
+		seqs := make([]int, 0, len(iccChunks))
+		for seq := range iccChunks {
+			seqs = append(seqs, seq)
+		}
+		sort.Ints(seqs)
+		for _, seq := range seqs {
+			meta.icc = append(meta.icc, iccChunks[seq]...)
+		}
+	}
+	return
+}
+
+// PNG keeps a zlib compressed profile in iCCP and raw EXIF in eXIf.
+func pngMetadata(data []byte) (meta imageMetadata) {
+	pos := 8
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		if length < 0 || pos+8+length > len(data) {
+			break
+		}
+		chunk := data[pos+8 : pos+8+length]
+
+		switch chunkType {
+		case "iCCP":
+			// profile name, null separator, compression method, profile
+			nul := bytes.IndexByte(chunk, 0)
+			if nul >= 0 && nul+2 <= len(chunk) {
+				r, err := zlib.NewReader(bytes.NewReader(chunk[nul+2:]))
+				if err == nil {
+					meta.icc, _ = ioutil.ReadAll(r)
+					r.Close()
+				}
+			}
+		case "eXIf":
+			meta.orientation, _ = readTIFFTags(chunk)
+		case "IDAT", "IEND":
+			return
+		}
+		// length, type, data, crc
+		pos += 12 + length
+	}
+	return
+}
+
+// WebP is a RIFF file with optional ICCP and EXIF chunks.
+func webpMetadata(data []byte) (meta imageMetadata) {
+	pos := 12
+	for pos+8 <= len(data) {
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if size < 0 || pos+8+size > len(data) {
+			break
+		}
+		chunk := data[pos+8 : pos+8+size]
+
+		switch fourCC {
+		case "ICCP":
+			meta.icc = chunk
+		case "EXIF":
+			meta.orientation, _ = readTIFFTags(bytes.TrimPrefix(chunk, []byte("Exif\x00\x00")))
+		}
+		// Chunks are padded to an even size.
+		pos += 8 + size + size%2
+	}
+	return
+}
+
+// Reads the orientation (0x0112) and ICC profile (0x8773) tags from the
+// first IFD of a TIFF structure. EXIF blocks use the same layout.
+func readTIFFTags(data []byte) (orientation int, icc []byte) {
+	if len(data) < 8 {
+		return
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return
+	}
+
+	ifd := int(order.Uint32(data[4:]))
+	if ifd < 8 || ifd+2 > len(data) {
+		return
+	}
+	entries := int(order.Uint16(data[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(data) {
+			return
+		}
+		tag := order.Uint16(data[entry:])
+		count := int(order.Uint32(data[entry+4:]))
+
+		switch tag {
+		case 0x0112:
+			orientation = int(order.Uint16(data[entry+8:]))
+		case 0x8773:
+			offset := int(order.Uint32(data[entry+8:]))
+			if count > 0 && offset >= 0 && offset+count <= len(data) {
+				icc = data[offset : offset+count]
+			}
+		}
+	}
+	return
+}
+
+// Rotates and flips img so it is upright for the given EXIF orientation.
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	src := toNRGBA(img)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dstW, dstH := w, h
+	// Orientations 5 to 8 swap width and height.
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // flip horizontal
+				sx, sy = w-1-x, y
+			case 3: // rotate 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // flip vertical
+				sx, sy = x, h-1-y
+			case 5: // transpose
+				sx, sy = y, x
+			case 6: // rotate 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transverse
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate 90 counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			si := src.PixOffset(sx, sy)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// Copy of img as *image.NRGBA with bounds starting at (0, 0).
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,249 @@
+package imageManip
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"sort"
+)
+
+// Leaves the octree may hold while pixels are added. When there are more
+// the deepest, least populated branches are merged, so memory stays
+// bounded no matter the image size.
+const OCTREE_MAX_LEAVES = 512
+
+const octreeDepth = 8
+
+type octreeNode struct {
+	// Sums of the pixels that ended in this node, only kept for leaves.
+	r, g, b uint64
+	count   uint64
+	leaf    bool
+
+	children [8]*octreeNode
+}
+
+// Gervautz and Purgathofer's octree quantizer. Pixels are added one at a
+// time and nothing but the tree is kept.
+type Octree struct {
+	root *octreeNode
+	// Interior nodes by depth, the candidates for merging.
+	reducible [octreeDepth][]*octreeNode
+	leaves    int
+	maxLeaves int
+}
+
+// maxLeaves below 8 is raised to 8.
+func NewOctree(maxLeaves int) *Octree {
+	if maxLeaves < 8 {
+		maxLeaves = 8
+	}
+	return &Octree{root: &octreeNode{}, maxLeaves: maxLeaves}
+}
+
+func octreeIndex(r, g, b uint8, depth int) int {
+	shift := 7 - uint(depth)
+	return int((r>>shift)&1)<<2 | int((g>>shift)&1)<<1 | int((b>>shift)&1)
+}
+
+func (t *Octree) Add(c color.NRGBA) {
+	node := t.root
+	for depth := 0; ; depth++ {
+		if node.leaf {
+			break
+		}
+		if depth == octreeDepth {
+			node.leaf = true
+			t.leaves++
+			break
+		}
+		i := octreeIndex(c.R, c.G, c.B, depth)
+		if node.children[i] == nil {
+			node.children[i] = &octreeNode{}
+			if depth+1 < octreeDepth {
+				t.reducible[depth+1] = append(t.reducible[depth+1], node.children[i])
+			}
+		}
+		node = node.children[i]
+	}
+	node.r += uint64(c.R)
+	node.g += uint64(c.G)
+	node.b += uint64(c.B)
+	node.count++
+
+	for t.leaves > t.maxLeaves {
+		t.reduce()
+	}
+}
+
+// Merges the least populated node of the deepest level that has any into
+// a single leaf. Returns false when only the root is left to merge.
+func (t *Octree) reduce() bool {
+	depth := octreeDepth - 1
+	for depth > 0 && len(t.reducible[depth]) == 0 {
+		depth--
+	}
+	nodes := t.reducible[depth]
+	if len(nodes) == 0 {
+		return false
+	}
+
+	best, bestCount := 0, ^uint64(0)
+	for i, n := range nodes {
+		if c := n.subtreeCount(); c < bestCount {
+			best, bestCount = i, c
+		}
+	}
+	node := nodes[best]
+	t.reducible[depth] = append(nodes[:best], nodes[best+1:]...)
+
+	// The deepest reducible level only has leaves under it.
+	merged := 0
+	for i, child := range node.children {
+		if child == nil {
+			continue
+		}
+		node.r += child.r
+		node.g += child.g
+		node.b += child.b
+		node.count += child.count
+		if child.leaf {
+			merged++
+		}
+		node.children[i] = nil
+	}
+	node.leaf = true
+	t.leaves -= merged - 1
+	return true
+}
+
+func (n *octreeNode) subtreeCount() uint64 {
+	if n.leaf {
+		return n.count
+	}
+	total := n.count
+	for _, child := range n.children {
+		if child != nil {
+			total += child.subtreeCount()
+		}
+	}
+	return total
+}
+
+// Merges until at most count colors are left and returns them, most
+// populated first, with their pixel counts as frequencies.
+func (t *Octree) Palette(count int) []ColAndFreq {
+	// The tree merges whole branches, which flattens regions with lots of
+	// similar colors. It only goes down to a few times count, the rest is
+	// done by merging the closest leaves.
+	treeLeaves := count * 8
+	if treeLeaves < 64 {
+		treeLeaves = 64
+	}
+	for t.leaves > treeLeaves && t.reduce() {
+	}
+
+	var leaves []*octreeNode
+	var walk func(n *octreeNode)
+	walk = func(n *octreeNode) {
+		if n.leaf {
+			if n.count > 0 {
+				leaves = append(leaves, n)
+			}
+			return
+		}
+		for _, child := range n.children {
+			if child != nil {
+				walk(child)
+			}
+		}
+	}
+	walk(t.root)
+
+	for len(leaves) > count && len(leaves) > 1 {
+		bi, bj, best := 0, 1, -1.0
+		for i := range leaves {
+			for j := i + 1; j < len(leaves); j++ {
+				if d := leaves[i].distance(leaves[j]); best < 0 || d < best {
+					bi, bj, best = i, j, d
+				}
+			}
+		}
+		merged := &octreeNode{
+			r:     leaves[bi].r + leaves[bj].r,
+			g:     leaves[bi].g + leaves[bj].g,
+			b:     leaves[bi].b + leaves[bj].b,
+			count: leaves[bi].count + leaves[bj].count,
+		}
+		leaves[bi] = merged
+		leaves = append(leaves[:bj], leaves[bj+1:]...)
+	}
+
+	palette := make([]ColAndFreq, len(leaves))
+	for i, n := range leaves {
+		palette[i] = ColAndFreq{ColString: rgbPixelToHexString(n.average()), Frequency: int(n.count)}
+	}
+	sort.SliceStable(palette, func(i, j int) bool {
+		return palette[i].Frequency > palette[j].Frequency
+	})
+	return palette
+}
+
+func (n *octreeNode) average() []int {
+	return []int{int(n.r / n.count), int(n.g / n.count), int(n.b / n.count)}
+}
+
+// Squared RGB distance between the average colors of two leaves.
+func (n *octreeNode) distance(other *octreeNode) float64 {
+	a, b := n.average(), other.average()
+	dr, dg, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return float64(dr*dr + dg*dg + db*db)
+}
+
+// Streams the pixels of img through an octree. Fully transparent pixels
+// are skipped.
+func ExtractPaletteOctree(img image.Image, colsToExtract int) ([]ColAndFreq, error) {
+	if colsToExtract < 1 {
+		return nil, errors.New("In ExtractPaletteOctree: colsToExtract must be at least 1.")
+	}
+	maxLeaves := OCTREE_MAX_LEAVES
+	if colsToExtract > maxLeaves {
+		maxLeaves = colsToExtract
+	}
+	tree := NewOctree(maxLeaves)
+	eachPixel(img, func(c color.NRGBA) {
+		if c.A != 0 {
+			tree.Add(c)
+		}
+	})
+	return tree.Palette(colsToExtract), nil
+}
+
+// Calls f with every pixel of img without copying the image. NRGBA and
+// RGBA images are read directly, others through At.
+func eachPixel(img image.Image, f func(color.NRGBA)) {
+	b := img.Bounds()
+	switch src := img.(type) {
+	case *image.NRGBA:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			row := src.Pix[src.PixOffset(b.Min.X, y):src.PixOffset(b.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				f(color.NRGBA{row[i], row[i+1], row[i+2], row[i+3]})
+			}
+		}
+	case *image.RGBA:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			row := src.Pix[src.PixOffset(b.Min.X, y):src.PixOffset(b.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				c := color.RGBA{row[i], row[i+1], row[i+2], row[i+3]}
+				f(color.NRGBAModel.Convert(c).(color.NRGBA))
+			}
+		}
+	default:
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				f(color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA))
+			}
+		}
+	}
+}
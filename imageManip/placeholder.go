@@ -0,0 +1,70 @@
+package imageManip
+
+import (
+	"image"
+)
+
+// Placeholder hashes of an image, stored next to its palette.
+type Placeholders struct {
+	BlurHash  string `json:"blurHash"`
+	ThumbHash string `json:"thumbHash"`
+}
+
+// BlurHash with xComponents by yComponents components, and ThumbHash.
+func NewPlaceholders(img image.Image, xComponents, yComponents int) (Placeholders, error) {
+	blur, err := BlurHashEncode(img, xComponents, yComponents)
+	if err != nil {
+		return Placeholders{}, err
+	}
+	thumb, err := ThumbHashEncode(img)
+	if err != nil {
+		return Placeholders{}, err
+	}
+	return Placeholders{BlurHash: blur, ThumbHash: thumb}, nil
+}
+
+// img box filtered down so neither side is over maxSide, keeping the
+// aspect ratio. Colors are averaged with alpha premultiplied so
+// transparent pixels don't darken their neighbours. Smaller images are
+// only copied.
+func thumbnail(img image.Image, maxSide int) *image.NRGBA {
+	src := toNRGBA(img)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w <= maxSide && h <= maxSide {
+		return src
+	}
+	tw, th := maxSide, maxSide
+	if w > h {
+		th = max(1, h*maxSide/w)
+	} else {
+		tw = max(1, w*maxSide/h)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	for ty := 0; ty < th; ty++ {
+		y0, y1 := ty*h/th, (ty+1)*h/th
+		for tx := 0; tx < tw; tx++ {
+			x0, x1 := tx*w/tw, (tx+1)*w/tw
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				row := src.Pix[src.PixOffset(x0, y):src.PixOffset(x1, y)]
+				for i := 0; i < len(row); i += 4 {
+					pa := uint64(row[i+3])
+					r += uint64(row[i]) * pa
+					g += uint64(row[i+1]) * pa
+					b += uint64(row[i+2]) * pa
+					a += pa
+					n++
+				}
+			}
+			i := dst.PixOffset(tx, ty)
+			if a > 0 {
+				dst.Pix[i] = uint8(r / a)
+				dst.Pix[i+1] = uint8(g / a)
+				dst.Pix[i+2] = uint8(b / a)
+				dst.Pix[i+3] = uint8(a / n)
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,200 @@
+package imageManip
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Swatch roles of Android's Palette API.
+const (
+	ROLE_LIGHT_VIBRANT = "light-vibrant"
+	ROLE_VIBRANT       = "vibrant"
+	ROLE_DARK_VIBRANT  = "dark-vibrant"
+	ROLE_LIGHT_MUTED   = "light-muted"
+	ROLE_MUTED         = "muted"
+	ROLE_DARK_MUTED    = "dark-muted"
+)
+
+// In the order the roles pick their swatch. A swatch taken by one role
+// can't be taken by a later one.
+var ROLES = []string{
+	ROLE_LIGHT_VIBRANT,
+	ROLE_VIBRANT,
+	ROLE_DARK_VIBRANT,
+	ROLE_LIGHT_MUTED,
+	ROLE_MUTED,
+	ROLE_DARK_MUTED,
+}
+
+// Colors Android's Palette generates from, more than a usual palette so
+// most roles find a swatch.
+const ROLE_CANDIDATES = 16
+
+// Minimum contrast of the text colors, the same as Android's.
+const (
+	ROLE_TITLE_CONTRAST = 3.0
+	ROLE_BODY_CONTRAST  = 4.5
+)
+
+// Android's scoring weights.
+const (
+	roleSaturationWeight = 0.24
+	roleLightnessWeight  = 0.52
+	rolePopulationWeight = 0.24
+)
+
+// HSL saturation and lightness a role accepts, with the value it scores
+// best at.
+type roleTarget struct {
+	minS, targetS, maxS float64
+	minL, targetL, maxL float64
+}
+
+var roleTargets = map[string]roleTarget{
+	ROLE_LIGHT_VIBRANT: {0.35, 1, 1, 0.55, 0.74, 1},
+	ROLE_VIBRANT:       {0.35, 1, 1, 0.3, 0.5, 0.7},
+	ROLE_DARK_VIBRANT:  {0.35, 1, 1, 0, 0.26, 0.45},
+	ROLE_LIGHT_MUTED:   {0, 0.3, 0.4, 0.55, 0.74, 1},
+	ROLE_MUTED:         {0, 0.3, 0.4, 0.3, 0.5, 0.7},
+	ROLE_DARK_MUTED:    {0, 0.3, 0.4, 0, 0.26, 0.45},
+}
+
+// A palette color picked for a role. The text colors are white or black
+// with the lowest alpha that still has enough contrast on the swatch, as
+// "#rrggbbaa".
+type RoleSwatch struct {
+	Role       string      `json:"role"`
+	Color      color.NRGBA `json:"-"`
+	Hex        string      `json:"color"`
+	Population int         `json:"population"`
+	TitleColor color.NRGBA `json:"-"`
+	TitleText  string      `json:"titleText"`
+	BodyColor  color.NRGBA `json:"-"`
+	BodyText   string      `json:"bodyText"`
+}
+
+// Picks a swatch from palette for each role, scoring colors by how close
+// their saturation and lightness are to the role's target and by their
+// frequency. Works on the output of any extractor; roles with no color in
+// range are left out.
+func PaletteRoles(palette []ColAndFreq) []RoleSwatch {
+	maxPopulation := 0
+	for _, c := range palette {
+		if c.Frequency > maxPopulation {
+			maxPopulation = c.Frequency
+		}
+	}
+
+	used := make(map[int]bool)
+	var ret []RoleSwatch
+	for _, role := range ROLES {
+		target := roleTargets[role]
+		best, bestScore := -1, 0.0
+		for i, c := range palette {
+			if used[i] {
+				continue
+			}
+			_, sat, l := NRGBAToHSL(HexToNRGBA(c.ColString))
+			if sat < target.minS || sat > target.maxS || l < target.minL || l > target.maxL {
+				continue
+			}
+			score := roleSaturationWeight*(1-math.Abs(sat-target.targetS)) +
+				roleLightnessWeight*(1-math.Abs(l-target.targetL))
+			if maxPopulation > 0 {
+				score += rolePopulationWeight * float64(c.Frequency) / float64(maxPopulation)
+			}
+			if best < 0 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		used[best] = true
+		ret = append(ret, NewRoleSwatch(role, HexToNRGBA(palette[best].ColString), palette[best].Frequency))
+	}
+	return ret
+}
+
+func NewRoleSwatch(role string, c color.NRGBA, population int) RoleSwatch {
+	title, body := RoleTextColors(c)
+	return RoleSwatch{
+		Role:       role,
+		Color:      c,
+		Hex:        NRGBAToHex(c),
+		Population: population,
+		TitleColor: title,
+		TitleText:  nrgbaToHexAlpha(title),
+		BodyColor:  body,
+		BodyText:   nrgbaToHexAlpha(body),
+	}
+}
+
+// Title and body text colors for bg like Android's Palette: white if
+// translucent white reaches both contrasts, else black if black does,
+// else whichever works for each.
+func RoleTextColors(bg color.NRGBA) (title, body color.NRGBA) {
+	white := color.NRGBA{255, 255, 255, 255}
+	black := color.NRGBA{0, 0, 0, 255}
+
+	whiteTitle := minimumTextAlpha(white, bg, ROLE_TITLE_CONTRAST)
+	whiteBody := minimumTextAlpha(white, bg, ROLE_BODY_CONTRAST)
+	if whiteTitle >= 0 && whiteBody >= 0 {
+		return withAlpha(white, whiteTitle), withAlpha(white, whiteBody)
+	}
+	blackTitle := minimumTextAlpha(black, bg, ROLE_TITLE_CONTRAST)
+	blackBody := minimumTextAlpha(black, bg, ROLE_BODY_CONTRAST)
+	if blackTitle >= 0 && blackBody >= 0 {
+		return withAlpha(black, blackTitle), withAlpha(black, blackBody)
+	}
+
+	if whiteTitle >= 0 {
+		title = withAlpha(white, whiteTitle)
+	} else {
+		title = withAlpha(black, blackTitle)
+	}
+	if whiteBody >= 0 {
+		body = withAlpha(white, whiteBody)
+	} else {
+		body = withAlpha(black, blackBody)
+	}
+	return
+}
+
+// Lowest alpha of fg drawn over bg with at least minRatio contrast, -1
+// if fully opaque fg doesn't reach it.
+func minimumTextAlpha(fg, bg color.NRGBA, minRatio float64) int {
+	if ContrastRatio(fg, bg) < minRatio {
+		return -1
+	}
+	low, high := 0, 255
+	for low < high {
+		mid := (low + high) / 2
+		if ContrastRatio(compositeOver(withAlpha(fg, mid), bg), bg) >= minRatio {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	return high
+}
+
+func withAlpha(c color.NRGBA, alpha int) color.NRGBA {
+	c.A = uint8(alpha)
+	return c
+}
+
+// fg blended over an opaque bg.
+func compositeOver(fg, bg color.NRGBA) color.NRGBA {
+	a := float64(fg.A) / 255
+	mix := func(f, b uint8) uint8 {
+		return uint8(math.Round(float64(f)*a + float64(b)*(1-a)))
+	}
+	return color.NRGBA{mix(fg.R, bg.R), mix(fg.G, bg.G), mix(fg.B, bg.B), 255}
+}
+
+// "#rrggbbaa"
+func nrgbaToHexAlpha(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
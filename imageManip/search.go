@@ -0,0 +1,340 @@
+package imageManip
+
+import (
+	"bufio"
+	"encoding/json"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+)
+
+// Colors further apart than this in OKLab don't match in ContainingColor
+// unless the query says otherwise.
+const SEARCH_MAX_DISTANCE = 0.1
+
+// Width of the cells of the color index.
+const searchCellSize = 0.05
+
+const searchEpsilon = 1e-9
+
+// A stored palette with its colors in OKLab and their share of the image.
+type indexedPalette struct {
+	path     string
+	palette  []ColAndFreq
+	labs     []OKLab
+	weights  []float64
+	centroid OKLab
+}
+
+// Where a color of the index came from.
+type colorRef struct {
+	entry, color int
+}
+
+// Palettes of a library of images, searchable by palette similarity (Earth
+// Mover's Distance) and by single colors (nearest neighbours in OKLab).
+type PaletteIndex struct {
+	entries []indexedPalette
+	cells   map[[3]int][]colorRef
+}
+
+// One ranked search hit. For color searches Match is the closest color of
+// the palette and Share its weight in the palette.
+type SearchResult struct {
+	Path     string       `json:"path"`
+	Distance float64      `json:"distance"`
+	Match    string       `json:"match,omitempty"`
+	Share    float64      `json:"share,omitempty"`
+	Palette  []ColAndFreq `json:"palette"`
+}
+
+func NewPaletteIndex() *PaletteIndex {
+	return &PaletteIndex{cells: make(map[[3]int][]colorRef)}
+}
+
+// Reads JSON lines with "path" and "palette" fields, like the manifests of
+// the batch command. Lines with an error or no palette are skipped.
+func ReadPaletteIndex(r io.Reader) (*PaletteIndex, error) {
+	idx := NewPaletteIndex()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var row struct {
+			Path    string       `json:"path"`
+			Palette []ColAndFreq `json:"palette"`
+			Error   string       `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			continue
+		}
+		if row.Error == "" {
+			idx.Add(row.Path, row.Palette)
+		}
+	}
+	return idx, scanner.Err()
+}
+
+func (idx *PaletteIndex) Len() int {
+	return len(idx.entries)
+}
+
+// Adds the palette of the image at path. Frequencies weight the colors,
+// a palette without any counts them equally.
+func (idx *PaletteIndex) Add(path string, palette []ColAndFreq) {
+	if len(palette) == 0 {
+		return
+	}
+	labs, weights := weightedLabs(palette)
+	entry := indexedPalette{
+		path:     path,
+		palette:  palette,
+		labs:     labs,
+		weights:  weights,
+		centroid: centroid(labs, weights),
+	}
+	idx.entries = append(idx.entries, entry)
+	for i, lab := range labs {
+		cell := densityCell(lab, searchCellSize)
+		idx.cells[cell] = append(idx.cells[cell], colorRef{len(idx.entries) - 1, i})
+	}
+}
+
+// The limit palettes closest to query by Earth Mover's Distance, closest
+// first. limit < 1 returns them all.
+func (idx *PaletteIndex) SimilarPalettes(query []ColAndFreq, limit int) []SearchResult {
+	if len(query) == 0 {
+		return nil
+	}
+	if limit < 1 || limit > len(idx.entries) {
+		limit = len(idx.entries)
+	}
+	labs, weights := weightedLabs(query)
+	center := centroid(labs, weights)
+
+	// The distance between centroids is a lower bound of the EMD, so
+	// palettes are tried in that order and the search stops once the
+	// bound passes the worst result kept.
+	bounds := make([]float64, len(idx.entries))
+	order := make([]int, len(idx.entries))
+	for i := range idx.entries {
+		bounds[i] = center.distance(idx.entries[i].centroid)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return bounds[order[a]] < bounds[order[b]]
+	})
+
+	var results []SearchResult
+	for _, i := range order {
+		if len(results) == limit && bounds[i] >= results[limit-1].Distance {
+			break
+		}
+		entry := &idx.entries[i]
+		d := earthMoversDistance(labs, weights, entry.labs, entry.weights)
+		if len(results) == limit && d >= results[limit-1].Distance {
+			continue
+		}
+		results = append(results, SearchResult{Path: entry.path, Distance: d, Palette: entry.palette})
+		sort.SliceStable(results, func(a, b int) bool {
+			return results[a].Distance < results[b].Distance
+		})
+		if len(results) > limit {
+			results = results[:limit]
+		}
+	}
+	return results
+}
+
+// Palettes with a color within maxDistance of c, by how close their
+// closest color is and then by its share. maxDistance <= 0 means
+// SEARCH_MAX_DISTANCE, limit < 1 returns every match.
+func (idx *PaletteIndex) ContainingColor(c color.NRGBA, maxDistance float64, limit int) []SearchResult {
+	if maxDistance <= 0 {
+		maxDistance = SEARCH_MAX_DISTANCE
+	}
+	lab := NRGBAToOKLab(c)
+	center := densityCell(lab, searchCellSize)
+	reach := int(math.Ceil(maxDistance / searchCellSize))
+
+	best := make(map[int]SearchResult)
+	for dl := -reach; dl <= reach; dl++ {
+		for da := -reach; da <= reach; da++ {
+			for db := -reach; db <= reach; db++ {
+				cell := [3]int{center[0] + dl, center[1] + da, center[2] + db}
+				for _, ref := range idx.cells[cell] {
+					entry := &idx.entries[ref.entry]
+					d := lab.distance(entry.labs[ref.color])
+					if d > maxDistance {
+						continue
+					}
+					share := entry.weights[ref.color]
+					if prev, ok := best[ref.entry]; ok &&
+						(prev.Distance < d || (prev.Distance == d && prev.Share >= share)) {
+						continue
+					}
+					best[ref.entry] = SearchResult{
+						Path:     entry.path,
+						Distance: d,
+						Match:    entry.palette[ref.color].ColString,
+						Share:    share,
+						Palette:  entry.palette,
+					}
+				}
+			}
+		}
+	}
+
+	results := make([]SearchResult, 0, len(best))
+	for _, res := range best {
+		results = append(results, res)
+	}
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Distance != results[b].Distance {
+			return results[a].Distance < results[b].Distance
+		}
+		if results[a].Share != results[b].Share {
+			return results[a].Share > results[b].Share
+		}
+		return results[a].Path < results[b].Path
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Earth Mover's Distance between two palettes in OKLab: the least total
+// color change, weighted by share, that turns one into the other.
+func PaletteEMD(a, b []ColAndFreq) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return math.Inf(1)
+	}
+	aLabs, aWeights := weightedLabs(a)
+	bLabs, bWeights := weightedLabs(b)
+	return earthMoversDistance(aLabs, aWeights, bLabs, bWeights)
+}
+
+// OKLab colors of palette and their weights, summing to 1.
+func weightedLabs(palette []ColAndFreq) ([]OKLab, []float64) {
+	labs := make([]OKLab, len(palette))
+	weights := make([]float64, len(palette))
+	total := 0
+	for _, c := range palette {
+		total += c.Frequency
+	}
+	for i, c := range palette {
+		labs[i] = NRGBAToOKLab(HexToNRGBA(c.ColString))
+		if total > 0 {
+			weights[i] = float64(c.Frequency) / float64(total)
+		} else {
+			weights[i] = 1 / float64(len(palette))
+		}
+	}
+	return labs, weights
+}
+
+func centroid(labs []OKLab, weights []float64) OKLab {
+	var c OKLab
+	for i, lab := range labs {
+		c.L += lab.L * weights[i]
+		c.A += lab.A * weights[i]
+		c.B += lab.B * weights[i]
+	}
+	return c
+}
+
+// Solves the transportation problem from supply to demand (both summing
+// to 1) with successive shortest paths. Palettes are small, Bellman-Ford
+// on the residual graph is fast enough and copes with the negative
+// backward edges.
+func earthMoversDistance(aLabs []OKLab, supply []float64, bLabs []OKLab, demand []float64) float64 {
+	n, m := len(aLabs), len(bLabs)
+	cost := make([][]float64, n)
+	flow := make([][]float64, n)
+	for i := range cost {
+		cost[i] = make([]float64, m)
+		flow[i] = make([]float64, m)
+		for j := range cost[i] {
+			cost[i][j] = aLabs[i].distance(bLabs[j])
+		}
+	}
+	supply = append([]float64(nil), supply...)
+	demand = append([]float64(nil), demand...)
+
+	// Nodes 0..n-1 are the colors of a, n..n+m-1 the colors of b.
+	dist := make([]float64, n+m)
+	prev := make([]int, n+m)
+	for iteration := 0; iteration < 4*(n+m)*(n+m); iteration++ {
+		for v := range dist {
+			dist[v] = math.Inf(1)
+			prev[v] = -1
+		}
+		for i := 0; i < n; i++ {
+			if supply[i] > searchEpsilon {
+				dist[i] = 0
+			}
+		}
+		for changed, round := true, 0; changed && round < n+m; round++ {
+			changed = false
+			for i := 0; i < n; i++ {
+				for j := 0; j < m; j++ {
+					if d := dist[i] + cost[i][j]; d < dist[n+j]-searchEpsilon {
+						dist[n+j], prev[n+j] = d, i
+						changed = true
+					}
+					if flow[i][j] > searchEpsilon {
+						if d := dist[n+j] - cost[i][j]; d < dist[i]-searchEpsilon {
+							dist[i], prev[i] = d, n+j
+							changed = true
+						}
+					}
+				}
+			}
+		}
+
+		sink := -1
+		for j := 0; j < m; j++ {
+			if demand[j] > searchEpsilon && !math.IsInf(dist[n+j], 1) &&
+				(sink < 0 || dist[n+j] < dist[n+sink]) {
+				sink = j
+			}
+		}
+		if sink < 0 {
+			break
+		}
+
+		// Walk back to the source, finding how much the path can carry.
+		amount := demand[sink]
+		v := n + sink
+		for prev[v] >= 0 {
+			u := prev[v]
+			if u >= n {
+				amount = math.Min(amount, flow[v][u-n])
+			}
+			v = u
+		}
+		amount = math.Min(amount, supply[v])
+
+		supply[v] -= amount
+		demand[sink] -= amount
+		v = n + sink
+		for prev[v] >= 0 {
+			u := prev[v]
+			if u < n {
+				flow[u][v-n] += amount
+			} else {
+				flow[v][u-n] -= amount
+			}
+			v = u
+		}
+	}
+
+	total := 0.0
+	for i := range flow {
+		for j := range flow[i] {
+			total += flow[i][j] * cost[i][j]
+		}
+	}
+	return total
+}
@@ -0,0 +1,30 @@
+package imageManip
+
+import (
+	"math"
+	"testing"
+)
+
+// OKLab L is 0 for black and 1 for white, so moving weight between them
+// costs its share.
+func TestPaletteEMD(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []ColAndFreq
+		want float64
+	}{
+		{"same", []ColAndFreq{{"#336699", 3}, {"#ffcc00", 1}}, []ColAndFreq{{"#336699", 3}, {"#ffcc00", 1}}, 0},
+		{"black to white", []ColAndFreq{{"#000000", 1}}, []ColAndFreq{{"#ffffff", 5}}, 1},
+		{"half moves", []ColAndFreq{{"#000000", 1}, {"#ffffff", 1}}, []ColAndFreq{{"#000000", 2}}, 0.5},
+		{"quarter moves", []ColAndFreq{{"#000000", 3}, {"#ffffff", 1}}, []ColAndFreq{{"#000000", 1}, {"#ffffff", 1}}, 0.25},
+		{"frequencies all 0 weigh equally", []ColAndFreq{{"#000000", 0}, {"#ffffff", 0}}, []ColAndFreq{{"#ffffff", 0}}, 0.5},
+	}
+	for _, tt := range tests {
+		if got := PaletteEMD(tt.a, tt.b); math.Abs(got-tt.want) > 1e-4 {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+		if got := PaletteEMD(tt.b, tt.a); math.Abs(got-tt.want) > 1e-4 {
+			t.Errorf("%s reversed: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package imageManip
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Swatch file formats other programs can import.
+var SWATCH_FORMATS = []string{"gpl", "hex"}
+
+// Writes palette in the named swatch format.
+func WriteSwatches(w io.Writer, format string, name string, palette []ColAndFreq) error {
+	switch format {
+	case "gpl":
+		return WriteGPL(w, name, palette)
+	case "hex":
+		return WriteHexList(w, palette)
+	default:
+		return fmt.Errorf("Unknown swatch format %q. Available: %v.", format, SWATCH_FORMATS)
+	}
+}
+
+// GIMP palette, also read by Inkscape, Krita and Aseprite. Each color is
+// named after its nearest named color.
+func WriteGPL(w io.Writer, name string, palette []ColAndFreq) error {
+	if _, err := fmt.Fprintf(w, "GIMP Palette\nName: %s\nColumns: %d\n#\n", name, len(palette)); err != nil {
+		return err
+	}
+	for _, c := range palette {
+		col := HexToNRGBA(c.ColString)
+		name, _ := NearestColorName(col, COLOR_NAMES_ALL)
+		_, err := fmt.Fprintf(w, "%3d %3d %3d\t%s %s\n", col.R, col.G, col.B, name.Label(), c.ColString)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Colors of a GIMP palette, with zero frequencies. Names are ignored.
+func ReadGPL(r io.Reader) ([]ColAndFreq, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "GIMP Palette" {
+		return nil, errors.New("In ReadGPL: Not a GIMP palette.")
+	}
+	var palette []ColAndFreq
+	for line := 2; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") ||
+			strings.HasPrefix(text, "Name:") || strings.HasPrefix(text, "Columns:") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("In ReadGPL: Bad color on line %d.", line)
+		}
+		var rgb [3]uint8
+		for i := range rgb {
+			v, err := strconv.ParseUint(fields[i], 10, 8)
+			if err != nil {
+				return nil, fmt.Errorf("In ReadGPL: Bad color on line %d.", line)
+			}
+			rgb[i] = uint8(v)
+		}
+		palette = append(palette, ColAndFreq{ColString: NRGBAToHex(color.NRGBA{rgb[0], rgb[1], rgb[2], 255})})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(palette) == 0 {
+		return nil, errors.New("In ReadGPL: No colors.")
+	}
+	return palette, nil
+}
+
+// One "#rrggbb" per line. Programs import this as a bare list of colors,
+// so there is no room for names; "gpl" has them.
+func WriteHexList(w io.Writer, palette []ColAndFreq) error {
+	for _, c := range palette {
+		if _, err := fmt.Fprintln(w, c.ColString); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package imageManip
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// A 16 color terminal scheme.
+type TerminalScheme struct {
+	Name       string
+	Background color.NRGBA
+	Foreground color.NRGBA
+	Cursor     color.NRGBA
+	CursorText color.NRGBA
+	Selection  color.NRGBA
+	// black, red, green, yellow, blue, magenta, cyan, white, then the
+	// bright variants in the same order.
+	ANSI [16]color.NRGBA
+}
+
+var ANSI_NAMES = [8]string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// OKLCH hues of the sRGB primaries and secondaries, the hue each ANSI
+// slot is expected to have. Black and white have no hue.
+var ansiTargetHues = [8]float64{0, 29, 142, 110, 264, 328, 195, 0}
+
+// Palette colors within this many degrees of a slot's target hue can
+// stand in for it.
+const ANSI_HUE_RANGE = 35
+
+// Minimum contrast of the 16 colors and the foreground against the
+// background.
+const (
+	TERMINAL_MIN_CONTRAST    = 4.5
+	TERMINAL_MIN_FG_CONTRAST = 7
+)
+
+// Maps palette onto a terminal scheme. The background and foreground come
+// from the darkest and lightest palette colors (swapped for light schemes),
+// each ANSI color takes the hue and chroma of the palette color closest
+// to its expected hue, falling back to the plain hue when the palette has
+// nothing near it. All colors are adjusted to be readable on the
+// background.
+func GenerateTerminalScheme(name string, palette []ColAndFreq, light bool) TerminalScheme {
+	lchs := make([]OKLCH, len(palette))
+	for i, c := range palette {
+		lchs[i] = NRGBAToOKLCH(HexToNRGBA(c.ColString))
+	}
+	byLightness := make([]OKLCH, len(lchs))
+	copy(byLightness, lchs)
+	sort.Slice(byLightness, func(i, j int) bool {
+		return byLightness[i].L < byLightness[j].L
+	})
+
+	// Tint of the background and foreground, kept subtle.
+	darkest, lightest := OKLCH{L: 0.2, H: 0}, OKLCH{L: 0.9, H: 0}
+	if len(byLightness) > 0 {
+		darkest = byLightness[0]
+		lightest = byLightness[len(byLightness)-1]
+	}
+	dark := OKLCH{L: math.Min(darkest.L, 0.22), C: math.Min(darkest.C, 0.03), H: darkest.H}
+	bright := OKLCH{L: math.Max(lightest.L, 0.9), C: math.Min(lightest.C, 0.03), H: lightest.H}
+
+	scheme := TerminalScheme{Name: name}
+	if light {
+		scheme.Background = OKLCHToNRGBA(OKLCH{L: math.Max(bright.L, 0.96), C: bright.C, H: bright.H})
+		scheme.Foreground = OKLCHToNRGBA(dark)
+	} else {
+		scheme.Background = OKLCHToNRGBA(dark)
+		scheme.Foreground = OKLCHToNRGBA(bright)
+	}
+	scheme.Foreground = EnsureContrast(scheme.Foreground, scheme.Background, TERMINAL_MIN_FG_CONTRAST)
+
+	// Normal and bright lightness of the hued colors.
+	normalL, brightL := 0.68, 0.78
+	if light {
+		normalL, brightL = 0.5, 0.42
+	}
+
+	// A palette color stands in for one slot at most, otherwise yellow and
+	// green often end up the same.
+	used := make(map[int]bool)
+	for i := 1; i <= 6; i++ {
+		hue, chroma := ansiTargetHues[i], 0.15
+		best := -1
+		for j, lch := range lchs {
+			// Greys don't have a meaningful hue.
+			if used[j] || lch.C < 0.04 || hueDistance(lch.H, ansiTargetHues[i]) > ANSI_HUE_RANGE {
+				continue
+			}
+			if best < 0 || hueDistance(lch.H, hue) < hueDistance(lchs[best].H, hue) {
+				best = j
+			}
+		}
+		if best >= 0 {
+			used[best] = true
+			hue = lchs[best].H
+			chroma = math.Max(lchs[best].C, 0.09)
+		}
+
+		normal := OKLCHToNRGBA(OKLCH{L: normalL, C: chroma, H: hue})
+		brightC := OKLCHToNRGBA(OKLCH{L: brightL, C: chroma * 1.1, H: hue})
+		scheme.ANSI[i] = EnsureContrast(normal, scheme.Background, TERMINAL_MIN_CONTRAST)
+		scheme.ANSI[i+8] = EnsureContrast(brightC, scheme.Background, TERMINAL_MIN_CONTRAST)
+	}
+
+	bg := NRGBAToOKLCH(scheme.Background)
+	fg := NRGBAToOKLCH(scheme.Foreground)
+	// black and bright black sit between the background and the middle,
+	// white and bright white between the middle and the foreground.
+	scheme.ANSI[0] = OKLCHToNRGBA(OKLCH{L: bg.L + (fg.L-bg.L)*0.15, C: bg.C, H: bg.H})
+	scheme.ANSI[8] = EnsureContrast(
+		OKLCHToNRGBA(OKLCH{L: bg.L + (fg.L-bg.L)*0.45, C: bg.C, H: bg.H}),
+		scheme.Background,
+		3,
+	)
+	scheme.ANSI[7] = EnsureContrast(
+		OKLCHToNRGBA(OKLCH{L: bg.L + (fg.L-bg.L)*0.8, C: fg.C, H: fg.H}),
+		scheme.Background,
+		TERMINAL_MIN_CONTRAST,
+	)
+	scheme.ANSI[15] = scheme.Foreground
+
+	// The cursor uses the most prominent colorful palette color.
+	scheme.Cursor = scheme.Foreground
+	for _, lch := range lchs {
+		if lch.C >= 0.04 {
+			scheme.Cursor = EnsureContrast(OKLCHToNRGBA(lch), scheme.Background, TERMINAL_MIN_CONTRAST)
+			break
+		}
+	}
+	scheme.CursorText = scheme.Background
+	scheme.Selection = OKLCHToNRGBA(OKLCH{L: bg.L + (fg.L-bg.L)*0.25, C: bg.C, H: bg.H})
+	return scheme
+}
+
+// Terminal config formats WriteTerminalScheme understands.
+var TERMINAL_FORMATS = []string{"xresources", "alacritty", "kitty", "windows-terminal", "foot", "wezterm"}
+
+// Writes the scheme in one of TERMINAL_FORMATS, with the nearest name of
+// each color in a comment above it. Windows Terminal schemes are plain
+// JSON, which has no comments, so they go without names.
+func WriteTerminalScheme(w io.Writer, format string, scheme TerminalScheme) error {
+	switch format {
+	case "xresources":
+		return writeXresources(w, scheme)
+	case "alacritty":
+		return writeAlacritty(w, scheme)
+	case "kitty":
+		return writeKitty(w, scheme)
+	case "windows-terminal":
+		return writeWindowsTerminal(w, scheme)
+	case "foot":
+		return writeFoot(w, scheme)
+	case "wezterm":
+		return writeWezterm(w, scheme)
+	default:
+		return fmt.Errorf("Unknown terminal format %q. Available: %v.", format, TERMINAL_FORMATS)
+	}
+}
+
+// Nearest name of c for the comments above each color.
+func colorLabel(c color.NRGBA) string {
+	name, _ := NearestColorName(c, COLOR_NAMES_ALL)
+	return name.Label()
+}
+
+func writeXresources(w io.Writer, s TerminalScheme) error {
+	var b strings.Builder
+	line := func(key string, c color.NRGBA) {
+		fmt.Fprintf(&b, "! %s\n*.%s: %s\n", colorLabel(c), key, NRGBAToHex(c))
+	}
+	fmt.Fprintf(&b, "! %s\n", s.Name)
+	line("foreground", s.Foreground)
+	line("background", s.Background)
+	line("cursorColor", s.Cursor)
+	for i, c := range s.ANSI {
+		line(fmt.Sprintf("color%d", i), c)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeAlacritty(w io.Writer, s TerminalScheme) error {
+	var b strings.Builder
+	line := func(key string, c color.NRGBA) {
+		fmt.Fprintf(&b, "# %s\n%s = \"%s\"\n", colorLabel(c), key, NRGBAToHex(c))
+	}
+	fmt.Fprintf(&b, "# %s\n", s.Name)
+	fmt.Fprintf(&b, "[colors.primary]\n")
+	line("background", s.Background)
+	line("foreground", s.Foreground)
+	fmt.Fprintf(&b, "\n[colors.cursor]\n")
+	line("text", s.CursorText)
+	line("cursor", s.Cursor)
+	fmt.Fprintf(&b, "\n[colors.selection]\ntext = \"CellForeground\"\n")
+	line("background", s.Selection)
+	for half, section := range []string{"normal", "bright"} {
+		fmt.Fprintf(&b, "\n[colors.%s]\n", section)
+		for i, name := range ANSI_NAMES {
+			line(name, s.ANSI[half*8+i])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeKitty(w io.Writer, s TerminalScheme) error {
+	var b strings.Builder
+	line := func(key string, c color.NRGBA) {
+		fmt.Fprintf(&b, "# %s\n%s %s\n", colorLabel(c), key, NRGBAToHex(c))
+	}
+	fmt.Fprintf(&b, "# %s\n", s.Name)
+	line("foreground", s.Foreground)
+	line("background", s.Background)
+	line("cursor", s.Cursor)
+	line("cursor_text_color", s.CursorText)
+	line("selection_background", s.Selection)
+	for i, c := range s.ANSI {
+		line(fmt.Sprintf("color%d", i), c)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeWindowsTerminal(w io.Writer, s TerminalScheme) error {
+	// Windows Terminal calls magenta "purple".
+	names := ANSI_NAMES
+	names[5] = "purple"
+
+	scheme := map[string]string{
+		"name":                s.Name,
+		"background":          NRGBAToHex(s.Background),
+		"foreground":          NRGBAToHex(s.Foreground),
+		"cursorColor":         NRGBAToHex(s.Cursor),
+		"selectionBackground": NRGBAToHex(s.Selection),
+	}
+	for i, name := range names {
+		scheme[name] = NRGBAToHex(s.ANSI[i])
+		scheme["bright"+strings.ToUpper(name[:1])+name[1:]] = NRGBAToHex(s.ANSI[i+8])
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(scheme)
+}
+
+// foot wants hex without the leading "#".
+func writeFoot(w io.Writer, s TerminalScheme) error {
+	hex := func(c color.NRGBA) string { return NRGBAToHex(c)[1:] }
+	var b strings.Builder
+	line := func(key string, c color.NRGBA) {
+		fmt.Fprintf(&b, "# %s\n%s=%s\n", colorLabel(c), key, hex(c))
+	}
+	fmt.Fprintf(&b, "# %s\n[colors]\n", s.Name)
+	line("foreground", s.Foreground)
+	line("background", s.Background)
+	line("selection-background", s.Selection)
+	for i := 0; i < 8; i++ {
+		line(fmt.Sprintf("regular%d", i), s.ANSI[i])
+	}
+	for i := 0; i < 8; i++ {
+		line(fmt.Sprintf("bright%d", i), s.ANSI[i+8])
+	}
+	fmt.Fprintf(&b, "\n[cursor]\n# %s on %s\ncolor=%s %s\n",
+		colorLabel(s.CursorText), colorLabel(s.Cursor), hex(s.CursorText), hex(s.Cursor))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeWezterm(w io.Writer, s TerminalScheme) error {
+	var b strings.Builder
+	line := func(key string, c color.NRGBA) {
+		fmt.Fprintf(&b, "# %s\n%s = \"%s\"\n", colorLabel(c), key, NRGBAToHex(c))
+	}
+	// The names of a list go in a comment above it, in the same order.
+	list := func(key string, colors []color.NRGBA) {
+		quoted := make([]string, len(colors))
+		labels := make([]string, len(colors))
+		for i, c := range colors {
+			quoted[i] = "\"" + NRGBAToHex(c) + "\""
+			labels[i] = colorLabel(c)
+		}
+		fmt.Fprintf(&b, "# %s\n%s = [%s]\n", strings.Join(labels, ", "), key, strings.Join(quoted, ", "))
+	}
+	fmt.Fprintf(&b, "[colors]\n")
+	line("foreground", s.Foreground)
+	line("background", s.Background)
+	line("cursor_bg", s.Cursor)
+	line("cursor_fg", s.CursorText)
+	line("cursor_border", s.Cursor)
+	line("selection_bg", s.Selection)
+	line("selection_fg", s.Foreground)
+	list("ansi", s.ANSI[:8])
+	list("brights", s.ANSI[8:])
+	fmt.Fprintf(&b, "\n[metadata]\nname = \"%s\"\n", s.Name)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,310 @@
+package imageManip
+
+import (
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+)
+
+// ThumbHash (https://evanw.github.io/thumbhash/): like BlurHash but with
+// alpha and the aspect ratio in the hash, and colors in a luminance and
+// two chroma channels. Hashes are base64 strings of the raw bytes.
+
+// Largest side ThumbHash encodes, bigger images are shrunk first.
+const thumbHashMaxSide = 100
+
+// DCT of one channel, the AC terms normalized to [0, 1].
+func thumbHashEncodeChannel(channel []float64, w, h, nx, ny int) (dc float64, ac []float64, scale float64) {
+	fx := make([]float64, w)
+	for cy := 0; cy < ny; cy++ {
+		for cx := 0; cx*ny < nx*(ny-cy); cx++ {
+			f := 0.0
+			for x := 0; x < w; x++ {
+				fx[x] = math.Cos(math.Pi / float64(w) * float64(cx) * (float64(x) + 0.5))
+			}
+			for y := 0; y < h; y++ {
+				fy := math.Cos(math.Pi / float64(h) * float64(cy) * (float64(y) + 0.5))
+				for x := 0; x < w; x++ {
+					f += channel[x+y*w] * fx[x] * fy
+				}
+			}
+			f /= float64(w * h)
+			if cx > 0 || cy > 0 {
+				ac = append(ac, f)
+				scale = math.Max(scale, math.Abs(f))
+			} else {
+				dc = f
+			}
+		}
+	}
+	if scale > 0 {
+		for i := range ac {
+			ac[i] = 0.5 + 0.5/scale*ac[i]
+		}
+	}
+	return
+}
+
+func ThumbHashEncode(img image.Image) (string, error) {
+	src := thumbnail(img, thumbHashMaxSide)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w == 0 || h == 0 {
+		return "", errors.New("In ThumbHashEncode: Empty image.")
+	}
+	pixel := func(i int) (r, g, b, a float64) {
+		p := src.Pix[i*4 : i*4+4]
+		return float64(p[0]) / 255, float64(p[1]) / 255, float64(p[2]) / 255, float64(p[3]) / 255
+	}
+
+	// Average color, transparent pixels are drawn over it.
+	avgR, avgG, avgB, avgA := 0.0, 0.0, 0.0, 0.0
+	for i := 0; i < w*h; i++ {
+		r, g, b, a := pixel(i)
+		avgR += a * r
+		avgG += a * g
+		avgB += a * b
+		avgA += a
+	}
+	if avgA > 0 {
+		avgR /= avgA
+		avgG /= avgA
+		avgB /= avgA
+	}
+
+	hasAlpha := avgA < float64(w*h)
+	lLimit := 7.0
+	if hasAlpha {
+		// Fewer luminance bits to make room for alpha.
+		lLimit = 5
+	}
+	side := math.Max(float64(w), float64(h))
+	lx := int(math.Max(1, math.Round(lLimit*float64(w)/side)))
+	ly := int(math.Max(1, math.Round(lLimit*float64(h)/side)))
+
+	l := make([]float64, w*h)
+	p := make([]float64, w*h)
+	q := make([]float64, w*h)
+	alpha := make([]float64, w*h)
+	for i := 0; i < w*h; i++ {
+		r, g, b, a := pixel(i)
+		r = avgR*(1-a) + a*r
+		g = avgG*(1-a) + a*g
+		b = avgB*(1-a) + a*b
+		l[i] = (r + g + b) / 3
+		p[i] = (r+g)/2 - b
+		q[i] = r - g
+		alpha[i] = a
+	}
+
+	lDC, lAC, lScale := thumbHashEncodeChannel(l, w, h, max(3, lx), max(3, ly))
+	pDC, pAC, pScale := thumbHashEncodeChannel(p, w, h, 3, 3)
+	qDC, qAC, qScale := thumbHashEncodeChannel(q, w, h, 3, 3)
+
+	isLandscape := w > h
+	header24 := int(math.Round(63*lDC)) |
+		int(math.Round(31.5+31.5*pDC))<<6 |
+		int(math.Round(31.5+31.5*qDC))<<12 |
+		int(math.Round(31*lScale))<<18
+	if hasAlpha {
+		header24 |= 1 << 23
+	}
+	header16 := int(math.Round(63*pScale))<<3 | int(math.Round(63*qScale))<<9
+	if isLandscape {
+		header16 |= ly | 1<<15
+	} else {
+		header16 |= lx
+	}
+	hash := []byte{byte(header24), byte(header24 >> 8), byte(header24 >> 16), byte(header16), byte(header16 >> 8)}
+
+	acs := [][]float64{lAC, pAC, qAC}
+	if hasAlpha {
+		aDC, aAC, aScale := thumbHashEncodeChannel(alpha, w, h, 5, 5)
+		hash = append(hash, byte(int(math.Round(15*aDC))|int(math.Round(15*aScale))<<4))
+		acs = append(acs, aAC)
+	}
+	acStart := len(hash)
+	acIndex := 0
+	for _, ac := range acs {
+		for _, f := range ac {
+			if acStart+acIndex>>1 >= len(hash) {
+				hash = append(hash, 0)
+			}
+			hash[acStart+acIndex>>1] |= byte(int(math.Round(15*f)) << uint((acIndex&1)<<2))
+			acIndex++
+		}
+	}
+	return base64.StdEncoding.EncodeToString(hash), nil
+}
+
+// Width over height of the image a hash was made from, roughly.
+func thumbHashAspectRatio(hash []byte) float64 {
+	hasAlpha := hash[2]&0x80 != 0
+	isLandscape := hash[4]&0x80 != 0
+	lx, ly := int(hash[3]&7), int(hash[3]&7)
+	rest := 7
+	if hasAlpha {
+		rest = 5
+	}
+	if isLandscape {
+		lx = rest
+	} else {
+		ly = rest
+	}
+	return float64(lx) / float64(ly)
+}
+
+// Renders a ThumbHash at its own size, 32 pixels on the long side.
+func ThumbHashDecode(encoded string) (image.Image, error) {
+	hash, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(hash) < 5 {
+		return nil, errors.New("In ThumbHashDecode: Hash is too short.")
+	}
+
+	header24 := int(hash[0]) | int(hash[1])<<8 | int(hash[2])<<16
+	header16 := int(hash[3]) | int(hash[4])<<8
+	lDC := float64(header24&63) / 63
+	pDC := float64(header24>>6&63)/31.5 - 1
+	qDC := float64(header24>>12&63)/31.5 - 1
+	lScale := float64(header24>>18&31) / 31
+	hasAlpha := header24>>23 != 0
+	pScale := float64(header16>>3&63) / 63
+	qScale := float64(header16>>9&63) / 63
+	isLandscape := header16>>15 != 0
+
+	rest := 7
+	if hasAlpha {
+		rest = 5
+	}
+	lx, ly := header16&7, rest
+	if isLandscape {
+		lx, ly = rest, header16&7
+	}
+	lx, ly = max(3, lx), max(3, ly)
+
+	aDC, aScale := 1.0, 0.0
+	acStart := 5
+	if hasAlpha {
+		if len(hash) < 6 {
+			return nil, errors.New("In ThumbHashDecode: Hash is too short.")
+		}
+		aDC = float64(hash[5]&15) / 15
+		aScale = float64(hash[5]>>4) / 15
+		acStart = 6
+	}
+
+	acIndex := 0
+	var decodeErr error
+	decodeChannel := func(nx, ny int, scale float64) []float64 {
+		var ac []float64
+		for cy := 0; cy < ny; cy++ {
+			cx := 1
+			if cy > 0 {
+				cx = 0
+			}
+			for ; cx*ny < nx*(ny-cy); cx++ {
+				i := acStart + acIndex>>1
+				if i >= len(hash) {
+					decodeErr = errors.New("In ThumbHashDecode: Hash is too short.")
+					return ac
+				}
+				v := int(hash[i]) >> uint((acIndex&1)<<2) & 15
+				ac = append(ac, (float64(v)/7.5-1)*scale)
+				acIndex++
+			}
+		}
+		return ac
+	}
+	lAC := decodeChannel(lx, ly, lScale)
+	// Saturation is boosted to make up for the quantization.
+	pAC := decodeChannel(3, 3, pScale*1.25)
+	qAC := decodeChannel(3, 3, qScale*1.25)
+	var aAC []float64
+	if hasAlpha {
+		aAC = decodeChannel(5, 5, aScale)
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	ratio := thumbHashAspectRatio(hash)
+	w, h := 32, 32
+	if ratio > 1 {
+		h = int(math.Round(32 / ratio))
+	} else {
+		w = int(math.Round(32 * ratio))
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	n := max(lx, 3)
+	m := max(ly, 3)
+	if hasAlpha {
+		n, m = max(n, 5), max(m, 5)
+	}
+	fx := make([]float64, n)
+	fy := make([]float64, m)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			l, p, q, a := lDC, pDC, qDC, aDC
+			for cx := range fx {
+				fx[cx] = math.Cos(math.Pi / float64(w) * (float64(x) + 0.5) * float64(cx))
+			}
+			for cy := range fy {
+				fy[cy] = math.Cos(math.Pi / float64(h) * (float64(y) + 0.5) * float64(cy))
+			}
+
+			j := 0
+			for cy := 0; cy < ly; cy++ {
+				cx := 1
+				if cy > 0 {
+					cx = 0
+				}
+				for ; cx*ly < lx*(ly-cy); cx++ {
+					l += lAC[j] * fx[cx] * fy[cy] * 2
+					j++
+				}
+			}
+			j = 0
+			for cy := 0; cy < 3; cy++ {
+				cx := 1
+				if cy > 0 {
+					cx = 0
+				}
+				for ; cx < 3-cy; cx++ {
+					f := fx[cx] * fy[cy] * 2
+					p += pAC[j] * f
+					q += qAC[j] * f
+					j++
+				}
+			}
+			if hasAlpha {
+				j = 0
+				for cy := 0; cy < 5; cy++ {
+					cx := 1
+					if cy > 0 {
+						cx = 0
+					}
+					for ; cx < 5-cy; cx++ {
+						a += aAC[j] * fx[cx] * fy[cy] * 2
+						j++
+					}
+				}
+			}
+
+			b := l - 2.0/3*p
+			r := (3*l - b + q) / 2
+			g := r - q
+			img.SetNRGBA(x, y, color.NRGBA{
+				uint8(255 * clamp01(r)),
+				uint8(255 * clamp01(g)),
+				uint8(255 * clamp01(b)),
+				uint8(255 * clamp01(a)),
+			})
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,58 @@
+package imageManip
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// Expected values from the reference JavaScript implementation.
+func TestThumbHashEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		want string
+	}{
+		{"landscape", testImage(32, 24, false), "X/gJLZpwdndwd3d3h2h3d6CACvh3"},
+		{"portrait with alpha", testImage(20, 30, true), "X/iFGxAJcIB3aHixkAr3eHVGhYB3V2g="},
+	}
+	for _, tt := range tests {
+		got, err := ThumbHashEncode(tt.img)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestThumbHashDecode(t *testing.T) {
+	tests := []struct {
+		hash          string
+		width, height int
+		// First, middle (plus 3) and last pixel.
+		want [3]color.NRGBA
+	}{
+		{"X/gJLZpwdndwd3d3h2h3d6CACvh3", 32, 23, [3]color.NRGBA{{0, 5, 51, 255}, {150, 107, 129, 255}, {239, 251, 95, 255}}},
+		{"X/iFGxAJcIB3aHixkAr3eHVGhYB3V2g=", 19, 32, [3]color.NRGBA{{78, 88, 97, 153}, {70, 142, 119, 153}, {188, 198, 94, 153}}},
+	}
+	for _, tt := range tests {
+		img, err := ThumbHashDecode(tt.hash)
+		if err != nil {
+			t.Errorf("%s: %v", tt.hash, err)
+			continue
+		}
+		b := img.Bounds()
+		if b.Dx() != tt.width || b.Dy() != tt.height {
+			t.Errorf("%s: size %dx%d, want %dx%d", tt.hash, b.Dx(), b.Dy(), tt.width, tt.height)
+			continue
+		}
+		for i, n := range []int{0, tt.width*tt.height/2 + 3, tt.width*tt.height - 1} {
+			x, y := b.Min.X+n%tt.width, b.Min.Y+n/tt.width
+			got := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			if !nearNRGBA(got, tt.want[i]) {
+				t.Errorf("%s at (%d, %d): got %v, want %v", tt.hash, x, y, got, tt.want[i])
+			}
+		}
+	}
+}
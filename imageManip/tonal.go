@@ -0,0 +1,95 @@
+package imageManip
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// One step of a tonal scale, e.g. Tailwind's 500 or Material's tone 40.
+type Shade struct {
+	Step  int         `json:"step"`
+	Color color.NRGBA `json:"-"`
+	Hex   string      `json:"color"`
+}
+
+// Tonal scales TonalPalette can build.
+const (
+	TONAL_SCALE_TAILWIND = "tailwind"
+	TONAL_SCALE_MATERIAL = "material"
+)
+
+var TONAL_SCALES = []string{TONAL_SCALE_TAILWIND, TONAL_SCALE_MATERIAL}
+
+var TAILWIND_STEPS = []int{50, 100, 200, 300, 400, 500, 600, 700, 800, 900, 950}
+
+// Material tones are CIE L* values, 0 is black and 100 white.
+var MATERIAL_TONES = []int{0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 95, 99, 100}
+
+// OKLCH lightness of each Tailwind step and its chroma relative to the
+// 500 step, taken from Tailwind's own color scales.
+var (
+	tailwindLightness = []float64{0.971, 0.936, 0.885, 0.808, 0.704, 0.637, 0.577, 0.505, 0.444, 0.396, 0.258}
+	tailwindChroma    = []float64{0.055, 0.135, 0.26, 0.46, 0.8, 1, 1.03, 0.9, 0.75, 0.6, 0.39}
+)
+
+// Tonal ramp of seed in one of TONAL_SCALES, built in OKLCH. The hue of
+// seed is kept for every step and chroma is reduced only where a step
+// doesn't fit in sRGB.
+func TonalPalette(seed color.NRGBA, scale string) ([]Shade, error) {
+	switch scale {
+	case TONAL_SCALE_TAILWIND:
+		return TailwindShades(seed), nil
+	case TONAL_SCALE_MATERIAL:
+		return MaterialTones(seed), nil
+	default:
+		return nil, fmt.Errorf("Unknown tonal scale %q. Available: %v.", scale, TONAL_SCALES)
+	}
+}
+
+// 50 to 950 shades of c in the style of Tailwind's color scales.
+func TailwindShades(c color.NRGBA) []Shade {
+	lch := NRGBAToOKLCH(c)
+	shades := make([]Shade, len(TAILWIND_STEPS))
+	for i, step := range TAILWIND_STEPS {
+		shades[i] = newShade(step, OKLCH{
+			L: tailwindLightness[i],
+			C: lch.C * tailwindChroma[i],
+			H: lch.H,
+		})
+	}
+	return shades
+}
+
+// Tones 0 to 100 of c in the style of Material's tonal palettes. Chroma
+// stays at c's chroma wherever sRGB allows it.
+func MaterialTones(c color.NRGBA) []Shade {
+	lch := NRGBAToOKLCH(c)
+	shades := make([]Shade, len(MATERIAL_TONES))
+	for i, tone := range MATERIAL_TONES {
+		chroma := lch.C
+		// Pure black and white, rounding would tint them otherwise.
+		if tone == 0 || tone == 100 {
+			chroma = 0
+		}
+		shades[i] = newShade(tone, OKLCH{L: toneToOKLabL(float64(tone)), C: chroma, H: lch.H})
+	}
+	return shades
+}
+
+// OKLab lightness of a grey with CIE lightness tone.
+func toneToOKLabL(tone float64) float64 {
+	var y float64
+	if tone > 8 {
+		y = math.Pow((tone+16)/116, 3)
+	} else {
+		y = tone / 903.3
+	}
+	// For greys OKLab L is the cube root of luminance.
+	return math.Cbrt(y)
+}
+
+func newShade(step int, lch OKLCH) Shade {
+	col := OKLCHToNRGBA(lch)
+	return Shade{Step: step, Color: col, Hex: NRGBAToHex(col)}
+}
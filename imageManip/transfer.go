@@ -0,0 +1,276 @@
+package imageManip
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Ways TransferPalette can recolor an image.
+const (
+	// Reinhard et al. (2001): the mean and spread of each channel are
+	// moved to the target's. Done in OKLab, which like Reinhard's lαβ
+	// keeps lightness and the two color axes apart.
+	TRANSFER_REINHARD = "reinhard"
+	// Each RGB channel's histogram is matched to the target's.
+	TRANSFER_HISTOGRAM = "histogram"
+	// Pixels are grouped by their nearest source palette color and each
+	// group is moved by the offset from that color to its target.
+	TRANSFER_CLUSTER = "cluster"
+)
+
+var TRANSFER_METHODS = []string{TRANSFER_REINHARD, TRANSFER_HISTOGRAM, TRANSFER_CLUSTER}
+
+// A palette of a few colors makes a histogram of a few spikes. Each color
+// is spread over this many levels (standard deviation) so the matched
+// channels keep their gradients.
+const transferHistogramSpread = 20
+
+// Pixels about as close to two source colors blend their offsets instead
+// of jumping from one to the other. RGB distance, how much further than
+// the nearest color a color can be and still have weight.
+const transferClusterSoftness = 30.0
+
+// Recolors img with the target palette using one of TRANSFER_METHODS.
+// Target frequencies weight its colors, equally when they are all 0.
+// algorithm and opts extract the source palette of the cluster method,
+// opts.Goroutines is used by all of them. Alpha is kept.
+func TransferPalette(img image.Image, target []ColAndFreq, method string, algorithm string, opts Options) (*image.NRGBA, error) {
+	if len(target) == 0 {
+		return nil, errors.New("In TransferPalette: Empty target palette.")
+	}
+	switch method {
+	case TRANSFER_REINHARD:
+		return transferReinhard(img, target, opts.Goroutines), nil
+	case TRANSFER_HISTOGRAM:
+		return transferHistogram(img, target, opts.Goroutines), nil
+	case TRANSFER_CLUSTER:
+		return transferCluster(img, target, algorithm, opts)
+	default:
+		return nil, fmt.Errorf("Unknown transfer method %q. Available: %v.", method, TRANSFER_METHODS)
+	}
+}
+
+// Palette to transfer: a .gpl file, or the palette algorithm extracts
+// from an image.
+func LoadPalette(path string, algorithm string, opts Options) ([]ColAndFreq, error) {
+	if strings.ToLower(filepath.Ext(path)) == ".gpl" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ReadGPL(f)
+	}
+	img, err := LoadImage(path)
+	if err != nil {
+		return nil, err
+	}
+	return Extract(algorithm, img, opts)
+}
+
+// Copy of img with f applied to every pixel, rows split between
+// goroutines like SimulateCVDImage.
+func mapPixels(img image.Image, goroutines int, f func(color.NRGBA) color.NRGBA) *image.NRGBA {
+	out := toNRGBA(img)
+	if goroutines < 1 {
+		goroutines = runtime.NumCPU()
+	}
+	h := out.Rect.Dy()
+	var wg sync.WaitGroup
+	for w := 0; w < goroutines; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for y := w; y < h; y += goroutines {
+				row := out.Pix[y*out.Stride : y*out.Stride+4*out.Rect.Dx()]
+				for i := 0; i < len(row); i += 4 {
+					c := f(color.NRGBA{row[i], row[i+1], row[i+2], row[i+3]})
+					row[i], row[i+1], row[i+2] = c.R, c.G, c.B
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	return out
+}
+
+func transferReinhard(img image.Image, target []ColAndFreq, goroutines int) *image.NRGBA {
+	// Source statistics over the opaque pixels.
+	var sum, sumSq [3]float64
+	n := 0.0
+	eachPixel(img, func(c color.NRGBA) {
+		if c.A == 0 {
+			return
+		}
+		lab := NRGBAToOKLab(c)
+		for i, v := range [3]float64{lab.L, lab.A, lab.B} {
+			sum[i] += v
+			sumSq[i] += v * v
+		}
+		n++
+	})
+	if n == 0 {
+		return toNRGBA(img)
+	}
+	var srcMean, srcStd [3]float64
+	for i := range sum {
+		srcMean[i] = sum[i] / n
+		srcStd[i] = math.Sqrt(math.Max(0, sumSq[i]/n-srcMean[i]*srcMean[i]))
+	}
+
+	labs, weights := weightedLabs(target)
+	var dstMean, dstStd [3]float64
+	for j, lab := range labs {
+		for i, v := range [3]float64{lab.L, lab.A, lab.B} {
+			dstMean[i] += weights[j] * v
+			dstStd[i] += weights[j] * v * v
+		}
+	}
+	var scale [3]float64
+	for i := range dstStd {
+		dstStd[i] = math.Sqrt(math.Max(0, dstStd[i]-dstMean[i]*dstMean[i]))
+		scale[i] = 1
+		if srcStd[i] > 0 {
+			scale[i] = dstStd[i] / srcStd[i]
+		}
+	}
+
+	return mapPixels(img, goroutines, func(c color.NRGBA) color.NRGBA {
+		lab := NRGBAToOKLab(c)
+		return OKLabToNRGBA(OKLab{
+			L: (lab.L-srcMean[0])*scale[0] + dstMean[0],
+			A: (lab.A-srcMean[1])*scale[1] + dstMean[1],
+			B: (lab.B-srcMean[2])*scale[2] + dstMean[2],
+		})
+	})
+}
+
+func transferHistogram(img image.Image, target []ColAndFreq, goroutines int) *image.NRGBA {
+	var srcHist, dstHist [3][256]float64
+	eachPixel(img, func(c color.NRGBA) {
+		if c.A == 0 {
+			return
+		}
+		srcHist[0][c.R]++
+		srcHist[1][c.G]++
+		srcHist[2][c.B]++
+	})
+
+	_, weights := weightedLabs(target)
+	for j, c := range target {
+		col := HexToNRGBA(c.ColString)
+		for i, v := range [3]uint8{col.R, col.G, col.B} {
+			for level := range dstHist[i] {
+				d := float64(level-int(v)) / transferHistogramSpread
+				dstHist[i][level] += weights[j] * math.Exp(-d*d/2)
+			}
+		}
+	}
+
+	// For each level the lowest target level whose cumulative share
+	// reaches the source level's.
+	var lut [3][256]uint8
+	for i := range lut {
+		srcCDF, dstCDF := cumulative(srcHist[i][:]), cumulative(dstHist[i][:])
+		level := 0
+		for v := range lut[i] {
+			for level < 255 && dstCDF[level] < srcCDF[v] {
+				level++
+			}
+			lut[i][v] = uint8(level)
+		}
+	}
+
+	return mapPixels(img, goroutines, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{lut[0][c.R], lut[1][c.G], lut[2][c.B], c.A}
+	})
+}
+
+// Running sums of hist scaled to end at 1.
+func cumulative(hist []float64) []float64 {
+	cdf := make([]float64, len(hist))
+	total := 0.0
+	for i, v := range hist {
+		total += v
+		cdf[i] = total
+	}
+	if total > 0 {
+		for i := range cdf {
+			cdf[i] /= total
+		}
+	}
+	return cdf
+}
+
+func transferCluster(img image.Image, target []ColAndFreq, algorithm string, opts Options) (*image.NRGBA, error) {
+	opts.Count = len(target)
+	source, err := Extract(algorithm, img, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(source) == 0 {
+		return nil, fmt.Errorf("In TransferPalette: %s found no colors in the image.", algorithm)
+	}
+
+	// Source colors are paired one to one with target colors so the
+	// total change is smallest, like DiffPalettes. Source colors left
+	// over take their nearest target.
+	sourceLabs, _ := weightedLabs(source)
+	targetLabs, _ := weightedLabs(target)
+	n := max(len(source), len(target))
+	cost := make([][]float64, n)
+	for i := range cost {
+		cost[i] = make([]float64, n)
+		for j := range cost[i] {
+			if i < len(source) && j < len(target) {
+				cost[i][j] = sourceLabs[i].distance(targetLabs[j])
+			}
+		}
+	}
+	assignment := hungarian(cost)
+	sourceRGB := make([][]int, len(source))
+	offsets := make([]OKLab, len(source))
+	for i, lab := range sourceLabs {
+		j := assignment[i]
+		if j >= len(target) {
+			j = 0
+			for k := range targetLabs {
+				if lab.distance(targetLabs[k]) < lab.distance(targetLabs[j]) {
+					j = k
+				}
+			}
+		}
+		c := HexToNRGBA(source[i].ColString)
+		sourceRGB[i] = []int{int(c.R), int(c.G), int(c.B)}
+		offsets[i] = OKLab{targetLabs[j].L - lab.L, targetLabs[j].A - lab.A, targetLabs[j].B - lab.B}
+	}
+
+	return mapPixels(img, opts.Goroutines, func(c color.NRGBA) color.NRGBA {
+		rgb := []int{int(c.R), int(c.G), int(c.B)}
+		nearest := sourceRGB[nearestColorIndex(sourceRGB, rgb)]
+		dMin := rgbDistanceSq(rgb, nearest)
+		var offset OKLab
+		total := 0.0
+		for i, src := range sourceRGB {
+			w := math.Exp(-(rgbDistanceSq(rgb, src) - dMin) / (2 * transferClusterSoftness * transferClusterSoftness))
+			offset.L += w * offsets[i].L
+			offset.A += w * offsets[i].A
+			offset.B += w * offsets[i].B
+			total += w
+		}
+		lab := NRGBAToOKLab(c)
+		return OKLabToNRGBA(OKLab{lab.L + offset.L/total, lab.A + offset.A/total, lab.B + offset.B/total})
+	}), nil
+}
+
+func rgbDistanceSq(a, b []int) float64 {
+	return sq(float64(a[0]-b[0])) + sq(float64(a[1]-b[1])) + sq(float64(a[2]-b[2]))
+}
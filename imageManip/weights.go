@@ -0,0 +1,240 @@
+package imageManip
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// Pixel weightings for the frequency extractor.
+const (
+	// Gaussian falloff from the center of the image.
+	WEIGHT_CENTER = "center"
+	// Local contrast, pixels near edges and detail count more than flat
+	// areas like skies and walls.
+	WEIGHT_SALIENCY = "saliency"
+)
+
+var WEIGHTINGS = []string{WEIGHT_CENTER, WEIGHT_SALIENCY}
+
+// A weighted pixel adds its weight times WEIGHT_SCALE to the color
+// frequency map, which keeps the map in integers.
+const WEIGHT_SCALE = 100
+
+// Standard deviation of the center weighting, as a fraction of the
+// distance from the center to the edges.
+const CENTER_WEIGHT_SIGMA = 0.5
+
+// Weight of perfectly flat areas in the saliency weighting, so they
+// still count a little.
+const SALIENCY_FLOOR = 0.1
+
+// Per-pixel weights in [0, 1] over the bounds of an image.
+type WeightMap struct {
+	Rect   image.Rectangle
+	Values []float32
+}
+
+func newWeightMap(r image.Rectangle) *WeightMap {
+	return &WeightMap{Rect: r, Values: make([]float32, r.Dx()*r.Dy())}
+}
+
+// Pixels outside the map weigh 0.
+func (w *WeightMap) At(x, y int) float64 {
+	if !(image.Point{x, y}.In(w.Rect)) {
+		return 0
+	}
+	return float64(w.Values[(y-w.Rect.Min.Y)*w.Rect.Dx()+x-w.Rect.Min.X])
+}
+
+func (w *WeightMap) set(x, y int, v float64) {
+	w.Values[(y-w.Rect.Min.Y)*w.Rect.Dx()+x-w.Rect.Min.X] = float32(v)
+}
+
+// What CountColors adds for the pixel at (x, y).
+func (w *WeightMap) count(x, y int) int {
+	return int(math.Round(w.At(x, y) * WEIGHT_SCALE))
+}
+
+// Multiplies w by other in place.
+func (w *WeightMap) multiply(other *WeightMap) {
+	for y := w.Rect.Min.Y; y < w.Rect.Max.Y; y++ {
+		for x := w.Rect.Min.X; x < w.Rect.Max.X; x++ {
+			w.set(x, y, w.At(x, y)*other.At(x, y))
+		}
+	}
+}
+
+// Weights falling off with distance from the center of r.
+func CenterWeights(r image.Rectangle) *WeightMap {
+	w := newWeightMap(r)
+	cx := float64(r.Min.X+r.Max.X-1) / 2
+	cy := float64(r.Min.Y+r.Max.Y-1) / 2
+	hw := math.Max(float64(r.Dx())/2, 1)
+	hh := math.Max(float64(r.Dy())/2, 1)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		dy := (float64(y) - cy) / hh
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dx := (float64(x) - cx) / hw
+			w.set(x, y, math.Exp(-(dx*dx+dy*dy)/(2*CENTER_WEIGHT_SIGMA*CENTER_WEIGHT_SIGMA)))
+		}
+	}
+	return w
+}
+
+// Local contrast of img: the luminance gradient, box blurred so the
+// areas around edges count and not only the edges themselves.
+func SaliencyWeights(img image.Image) *WeightMap {
+	src := toNRGBA(img)
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	w := newWeightMap(img.Bounds())
+	if width == 0 || height == 0 {
+		return w
+	}
+
+	lum := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := src.PixOffset(x, y)
+			lum[y*width+x] = 0.2126*float64(src.Pix[i]) + 0.7152*float64(src.Pix[i+1]) + 0.0722*float64(src.Pix[i+2])
+		}
+	}
+	at := func(x, y int) float64 {
+		if x < 0 {
+			x = 0
+		} else if x >= width {
+			x = width - 1
+		}
+		if y < 0 {
+			y = 0
+		} else if y >= height {
+			y = height - 1
+		}
+		return lum[y*width+x]
+	}
+
+	// Sobel magnitude, summed into an integral image for the blur.
+	integral := make([]float64, (width+1)*(height+1))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+			integral[(y+1)*(width+1)+x+1] = math.Hypot(gx, gy) +
+				integral[y*(width+1)+x+1] + integral[(y+1)*(width+1)+x] - integral[y*(width+1)+x]
+		}
+	}
+
+	radius := width
+	if height < radius {
+		radius = height
+	}
+	radius = radius/32 + 1
+	blurred := make([]float64, width*height)
+	maxValue := 0.0
+	for y := 0; y < height; y++ {
+		y0, y1 := max(y-radius, 0), min(y+radius+1, height)
+		for x := 0; x < width; x++ {
+			x0, x1 := max(x-radius, 0), min(x+radius+1, width)
+			sum := integral[y1*(width+1)+x1] - integral[y0*(width+1)+x1] -
+				integral[y1*(width+1)+x0] + integral[y0*(width+1)+x0]
+			v := sum / float64((y1-y0)*(x1-x0))
+			blurred[y*width+x] = v
+			if v > maxValue {
+				maxValue = v
+			}
+		}
+	}
+
+	b := img.Bounds()
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := 1.0
+			if maxValue > 0 {
+				v = blurred[y*width+x] / maxValue
+			}
+			w.set(b.Min.X+x, b.Min.Y+y, SALIENCY_FLOOR+(1-SALIENCY_FLOOR)*v)
+		}
+	}
+	return w
+}
+
+// Weights from a grayscale image, white weighs 1 and black 0. mask is
+// stretched over r, so a map made for the full size image also works
+// on a sampled one.
+func WeightsFromImage(mask image.Image, r image.Rectangle) *WeightMap {
+	w := newWeightMap(r)
+	mb := mask.Bounds()
+	if mb.Empty() {
+		return w
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		my := mb.Min.Y + (y-r.Min.Y)*mb.Dy()/r.Dy()
+		for x := r.Min.X; x < r.Max.X; x++ {
+			mx := mb.Min.X + (x-r.Min.X)*mb.Dx()/r.Dx()
+			red, green, blue, _ := mask.At(mx, my).RGBA()
+			w.set(x, y, (0.2126*float64(red)+0.7152*float64(green)+0.0722*float64(blue))/0xffff)
+		}
+	}
+	return w
+}
+
+// Returns an error if weighting is not "" or one of WEIGHTINGS.
+func CheckWeighting(weighting string) error {
+	if weighting == "" {
+		return nil
+	}
+	for _, w := range WEIGHTINGS {
+		if w == weighting {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown weighting %q. Available: %v.", weighting, WEIGHTINGS)
+}
+
+// Weight map for the weighting and weight map file in opts, multiplied
+// together when both are set. nil when every pixel counts the same.
+func optionWeights(img image.Image, opts Options) (*WeightMap, error) {
+	if err := CheckWeighting(opts.Weighting); err != nil {
+		return nil, err
+	}
+	var w *WeightMap
+	switch opts.Weighting {
+	case WEIGHT_CENTER:
+		w = CenterWeights(img.Bounds())
+	case WEIGHT_SALIENCY:
+		w = SaliencyWeights(img)
+	}
+
+	if opts.WeightMap != "" {
+		mask, err := LoadImage(opts.WeightMap)
+		if err != nil {
+			return nil, err
+		}
+		fromFile := WeightsFromImage(mask, img.Bounds())
+		if w == nil {
+			w = fromFile
+		} else {
+			w.multiply(fromFile)
+		}
+	}
+	return w, nil
+}
+
+// ExtractPaletteConcurrent with every pixel counted by its weight.
+// Frequencies are weighted pixel counts. minShare is a share of the total
+// weight, 0 uses MIN_COLOR_SHARE.
+func ExtractPaletteWeighted(
+	uploaded image.Image,
+	weights *WeightMap,
+	colsToExtract int,
+	numberOfGoroutines int,
+	tolerance float64,
+	minShare float64,
+) []ColAndFreq {
+	colorFrequencyMap := CreateWeightedColorFrequencyMap(uploaded, weights)
+	palette := paletteFromColFreqMap(colorFrequencyMap, colsToExtract, numberOfGoroutines, tolerance, minShare)
+	for i := range palette {
+		palette[i].Frequency /= WEIGHT_SCALE
+	}
+	return palette
+}
@@ -0,0 +1,292 @@
+package imageManip
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"sort"
+)
+
+// Xiaolin Wu's greedy orthogonal bipartition quantizer. The box with the
+// largest variance is cut in two until there are enough boxes, cuts are
+// found with cumulative moment tables so each one is cheap. It works on
+// the same 5-bit histogram as the MMCQ port.
+
+// Side of the moment tables. Index 0 is kept at zero so the cumulative
+// sums need no bounds checks.
+const wuSide = 1<<SIGBITS + 1
+
+const (
+	wuRed = iota
+	wuGreen
+	wuBlue
+)
+
+// Cumulative moments of the histogram: weight, per channel sums and the
+// sum of squares, all of box (0, 0, 0) to (r, g, b).
+type wuMoments struct {
+	wt, mr, mg, mb, m2 []float64
+}
+
+// A box of the histogram, lower bounds exclusive, upper ones inclusive.
+type wuBox struct {
+	r0, r1 int
+	g0, g1 int
+	b0, b1 int
+	vol    int
+}
+
+func wuIndex(r, g, b int) int {
+	return (r*wuSide+g)*wuSide + b
+}
+
+// Builds the moment tables of a histogram made by getHisto. Each bin
+// stands for the color at its center, like VBox.avg.
+func newWuMoments(histo map[int]int) *wuMoments {
+	size := wuSide * wuSide * wuSide
+	m := &wuMoments{
+		wt: make([]float64, size),
+		mr: make([]float64, size),
+		mg: make([]float64, size),
+		mb: make([]float64, size),
+		m2: make([]float64, size),
+	}
+	mask := 1<<SIGBITS - 1
+	half := 1 << RSHIFT / 2
+	for index, count := range histo {
+		r := index >> (2 * SIGBITS) & mask
+		g := index >> SIGBITS & mask
+		b := index & mask
+		cr := float64(r<<RSHIFT + half)
+		cg := float64(g<<RSHIFT + half)
+		cb := float64(b<<RSHIFT + half)
+		n := float64(count)
+
+		i := wuIndex(r+1, g+1, b+1)
+		m.wt[i] += n
+		m.mr[i] += n * cr
+		m.mg[i] += n * cg
+		m.mb[i] += n * cb
+		m.m2[i] += n * (cr*cr + cg*cg + cb*cb)
+	}
+
+	for _, table := range [][]float64{m.wt, m.mr, m.mg, m.mb, m.m2} {
+		for r := 1; r < wuSide; r++ {
+			var area [wuSide]float64
+			for g := 1; g < wuSide; g++ {
+				line := 0.0
+				for b := 1; b < wuSide; b++ {
+					line += table[wuIndex(r, g, b)]
+					area[b] += line
+					table[wuIndex(r, g, b)] = table[wuIndex(r-1, g, b)] + area[b]
+				}
+			}
+		}
+	}
+	return m
+}
+
+// Sum of table over box.
+func (box *wuBox) volume(table []float64) float64 {
+	return table[wuIndex(box.r1, box.g1, box.b1)] -
+		table[wuIndex(box.r1, box.g1, box.b0)] -
+		table[wuIndex(box.r1, box.g0, box.b1)] +
+		table[wuIndex(box.r1, box.g0, box.b0)] -
+		table[wuIndex(box.r0, box.g1, box.b1)] +
+		table[wuIndex(box.r0, box.g1, box.b0)] +
+		table[wuIndex(box.r0, box.g0, box.b1)] -
+		table[wuIndex(box.r0, box.g0, box.b0)]
+}
+
+// Part of volume that doesn't depend on where box is cut along dir.
+func (box *wuBox) bottom(dir int, table []float64) float64 {
+	switch dir {
+	case wuRed:
+		return -table[wuIndex(box.r0, box.g1, box.b1)] +
+			table[wuIndex(box.r0, box.g1, box.b0)] +
+			table[wuIndex(box.r0, box.g0, box.b1)] -
+			table[wuIndex(box.r0, box.g0, box.b0)]
+	case wuGreen:
+		return -table[wuIndex(box.r1, box.g0, box.b1)] +
+			table[wuIndex(box.r1, box.g0, box.b0)] +
+			table[wuIndex(box.r0, box.g0, box.b1)] -
+			table[wuIndex(box.r0, box.g0, box.b0)]
+	default:
+		return -table[wuIndex(box.r1, box.g1, box.b0)] +
+			table[wuIndex(box.r1, box.g0, box.b0)] +
+			table[wuIndex(box.r0, box.g1, box.b0)] -
+			table[wuIndex(box.r0, box.g0, box.b0)]
+	}
+}
+
+// Part of volume for a cut of box at pos along dir.
+func (box *wuBox) top(dir, pos int, table []float64) float64 {
+	switch dir {
+	case wuRed:
+		return table[wuIndex(pos, box.g1, box.b1)] -
+			table[wuIndex(pos, box.g1, box.b0)] -
+			table[wuIndex(pos, box.g0, box.b1)] +
+			table[wuIndex(pos, box.g0, box.b0)]
+	case wuGreen:
+		return table[wuIndex(box.r1, pos, box.b1)] -
+			table[wuIndex(box.r1, pos, box.b0)] -
+			table[wuIndex(box.r0, pos, box.b1)] +
+			table[wuIndex(box.r0, pos, box.b0)]
+	default:
+		return table[wuIndex(box.r1, box.g1, pos)] -
+			table[wuIndex(box.r1, box.g0, pos)] -
+			table[wuIndex(box.r0, box.g1, pos)] +
+			table[wuIndex(box.r0, box.g0, pos)]
+	}
+}
+
+// Weighted variance of the colors in box.
+func (m *wuMoments) variance(box *wuBox) float64 {
+	dr := box.volume(m.mr)
+	dg := box.volume(m.mg)
+	db := box.volume(m.mb)
+	wt := box.volume(m.wt)
+	if wt == 0 {
+		return 0
+	}
+	return box.volume(m.m2) - (dr*dr+dg*dg+db*db)/wt
+}
+
+// Finds the cut of box along dir between first and last that leaves the
+// least variance in the two halves. cut is -1 if no cut splits the pixels.
+func (m *wuMoments) maximize(box *wuBox, dir, first, last int, whole [4]float64) (best float64, cut int) {
+	baseR := box.bottom(dir, m.mr)
+	baseG := box.bottom(dir, m.mg)
+	baseB := box.bottom(dir, m.mb)
+	baseW := box.bottom(dir, m.wt)
+
+	cut = -1
+	for i := first; i < last; i++ {
+		halfR := baseR + box.top(dir, i, m.mr)
+		halfG := baseG + box.top(dir, i, m.mg)
+		halfB := baseB + box.top(dir, i, m.mb)
+		halfW := baseW + box.top(dir, i, m.wt)
+		if halfW == 0 {
+			continue
+		}
+		temp := (halfR*halfR + halfG*halfG + halfB*halfB) / halfW
+
+		halfR = whole[0] - halfR
+		halfG = whole[1] - halfG
+		halfB = whole[2] - halfB
+		halfW = whole[3] - halfW
+		if halfW == 0 {
+			continue
+		}
+		temp += (halfR*halfR + halfG*halfG + halfB*halfB) / halfW
+
+		if temp > best {
+			best = temp
+			cut = i
+		}
+	}
+	return
+}
+
+// Splits set1 in two along its best axis, the upper half goes in set2.
+// Returns false if set1 can't be split.
+func (m *wuMoments) cut(set1, set2 *wuBox) bool {
+	whole := [4]float64{
+		set1.volume(m.mr),
+		set1.volume(m.mg),
+		set1.volume(m.mb),
+		set1.volume(m.wt),
+	}
+	maxR, cutR := m.maximize(set1, wuRed, set1.r0+1, set1.r1, whole)
+	maxG, cutG := m.maximize(set1, wuGreen, set1.g0+1, set1.g1, whole)
+	maxB, cutB := m.maximize(set1, wuBlue, set1.b0+1, set1.b1, whole)
+
+	set2.r1, set2.g1, set2.b1 = set1.r1, set1.g1, set1.b1
+	switch {
+	case maxR >= maxG && maxR >= maxB:
+		if cutR < 0 {
+			return false
+		}
+		set2.r0, set1.r1 = cutR, cutR
+		set2.g0, set2.b0 = set1.g0, set1.b0
+	case maxG >= maxR && maxG >= maxB:
+		set2.g0, set1.g1 = cutG, cutG
+		set2.r0, set2.b0 = set1.r0, set1.b0
+	default:
+		set2.b0, set1.b1 = cutB, cutB
+		set2.r0, set2.g0 = set1.r0, set1.g0
+	}
+
+	set1.vol = (set1.r1 - set1.r0) * (set1.g1 - set1.g0) * (set1.b1 - set1.b0)
+	set2.vol = (set2.r1 - set2.r0) * (set2.g1 - set2.g0) * (set2.b1 - set2.b0)
+	return true
+}
+
+// Quantizes histo into at most colorCount colors. Frequencies are the
+// number of pixels in each box.
+func wuQuantize(histo map[int]int, colorCount int) []ColAndFreq {
+	m := newWuMoments(histo)
+
+	boxes := make([]wuBox, colorCount)
+	variances := make([]float64, colorCount)
+	boxes[0] = wuBox{r1: wuSide - 1, g1: wuSide - 1, b1: wuSide - 1}
+
+	next := 0
+	for i := 1; i < colorCount; i++ {
+		if m.cut(&boxes[next], &boxes[i]) {
+			variances[next], variances[i] = 0, 0
+			if boxes[next].vol > 1 {
+				variances[next] = m.variance(&boxes[next])
+			}
+			if boxes[i].vol > 1 {
+				variances[i] = m.variance(&boxes[i])
+			}
+		} else {
+			variances[next] = 0
+			i--
+		}
+
+		next = 0
+		for k := 1; k <= i; k++ {
+			if variances[k] > variances[next] {
+				next = k
+			}
+		}
+		if variances[next] <= 0 {
+			colorCount = i + 1
+			break
+		}
+	}
+
+	var palette []ColAndFreq
+	for _, box := range boxes[:colorCount] {
+		wt := box.volume(m.wt)
+		if wt == 0 {
+			continue
+		}
+		pixel := []int{
+			int(box.volume(m.mr) / wt),
+			int(box.volume(m.mg) / wt),
+			int(box.volume(m.mb) / wt),
+		}
+		palette = append(palette, ColAndFreq{ColString: rgbPixelToHexString(pixel), Frequency: int(wt)})
+	}
+	sort.SliceStable(palette, func(i, j int) bool {
+		return palette[i].Frequency > palette[j].Frequency
+	})
+	return palette
+}
+
+// Fully transparent pixels are skipped.
+func ExtractPaletteWu(img image.Image, colsToExtract int) ([]ColAndFreq, error) {
+	if colsToExtract < 1 {
+		return nil, errors.New("In ExtractPaletteWu: colsToExtract must be at least 1.")
+	}
+	histo := make(map[int]int)
+	eachPixel(img, func(c color.NRGBA) {
+		if c.A != 0 {
+			addToHisto(histo, int(c.R), int(c.G), int(c.B))
+		}
+	})
+	return wuQuantize(histo, colsToExtract), nil
+}
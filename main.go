@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 
+	"goPalettes/cli"
 	"goPalettes/ui"
 
 	"gioui.org/app"
@@ -16,15 +17,22 @@ import (
 var programState ui.State
 
 func main() {
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		if err := cli.Run(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	programState.Init()
 
-	/*
-		imgPath := "./images/basado1.png"
-		err := programState.SetCurImage(imgPath)
+	// An image path can be given as the first argument.
+	if len(os.Args) > 1 {
+		err := programState.SetCurImage(os.Args[1])
 		if err != nil {
 			log.Fatal(err)
 		}
-	*/
+	}
 
 	go func() {
 		w := app.NewWindow(
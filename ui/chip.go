@@ -0,0 +1,37 @@
+package ui
+
+import (
+	"image"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// Small clickable color square with a caption under it. click may be nil
+// for chips that only show a color.
+func (s *State) chip(gtx C, click *widget.Clickable, col color.NRGBA, caption string) D {
+	square := func(gtx C) D {
+		size := gtx.Dp(unit.Dp(colorBlockSize))
+		defer clip.Rect{Max: image.Point{size, size}}.Push(gtx.Ops).Pop()
+		paint.ColorOp{Color: col}.Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		return D{Size: image.Point{size, size}}
+	}
+	content := func(gtx C) D {
+		return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+			layout.Rigid(square),
+			layout.Rigid(material.Caption(s.th, caption).Layout),
+		)
+	}
+	return layout.UniformInset(unit.Dp(3)).Layout(gtx, func(gtx C) D {
+		if click == nil {
+			return content(gtx)
+		}
+		return click.Layout(gtx, content)
+	})
+}
@@ -0,0 +1,87 @@
+package ui
+
+import (
+	"fmt"
+	"goPalettes/imageManip"
+	"image"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget/material"
+)
+
+const contrastCellSize = 56
+
+// Grid of every palette color (plus black and white) as text on every
+// other one. Rows are backgrounds, columns text colors.
+func (s *State) contrastSection(gtx C) layout.Widget {
+	if !s.showContrast.Value || len(s.palette) == 0 {
+		return func(gtx C) D { return D{} }
+	}
+	m := imageManip.NewContrastMatrix(paletteColAndFreqs(s.palette))
+	margins := layout.Inset{
+		Left:  unit.Dp(MARGIN1),
+		Right: unit.Dp(MARGIN1),
+	}
+
+	return func(gtx C) D {
+		return margins.Layout(gtx, func(gtx C) D {
+			var rows []layout.FlexChild
+			for i := range m.Pairs {
+				i := i
+				var cells []layout.FlexChild
+				for j := range m.Pairs[i] {
+					if i == j {
+						continue
+					}
+					pair := m.Pairs[i][j]
+					cells = append(cells, layout.Rigid(func(gtx C) D {
+						return s.contrastCell(gtx, pair)
+					}))
+				}
+				rows = append(rows, layout.Rigid(func(gtx C) D {
+					return layout.Flex{}.Layout(gtx, cells...)
+				}))
+			}
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+		})
+	}
+}
+
+// The ratio in the pair's colors, with the best passing level and the
+// APCA Lc under it.
+func (s *State) contrastCell(gtx C, pair imageManip.ContrastPair) D {
+	bg := imageManip.HexToNRGBA(pair.Background)
+	fg := imageManip.HexToNRGBA(pair.Foreground)
+
+	level := "Fail"
+	switch {
+	case pair.AAANormal:
+		level = "AAA"
+	case pair.AANormal:
+		level = "AA"
+	case pair.AALarge:
+		level = "AA18"
+	}
+
+	size := image.Point{gtx.Dp(unit.Dp(contrastCellSize)), gtx.Dp(unit.Dp(contrastCellSize * 3 / 4))}
+	rect := clip.Rect{Max: size}.Push(gtx.Ops)
+	paint.ColorOp{Color: bg}.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	rect.Pop()
+
+	gtx.Constraints = layout.Exact(size)
+	layout.Center.Layout(gtx, func(gtx C) D {
+		ratio := material.Body2(s.th, fmt.Sprintf("%.1f", pair.Ratio))
+		ratio.Color = fg
+		detail := material.Caption(s.th, fmt.Sprintf("%s Lc%.0f", level, pair.APCA))
+		detail.Color = fg
+		return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+			layout.Rigid(ratio.Layout),
+			layout.Rigid(detail.Layout),
+		)
+	})
+	return D{Size: size}
+}
@@ -0,0 +1,133 @@
+package ui
+
+import (
+	"fmt"
+	"goPalettes/imageManip"
+	"image"
+	"image/color"
+	"log"
+	"strings"
+
+	"gioui.org/app"
+	"gioui.org/layout"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// Shows the image and palette as seen with a color vision deficiency.
+type cvdControls struct {
+	// "" for normal vision, otherwise one of imageManip.CVD_TYPES.
+	mode widget.Enum
+
+	// Simulation of source for kind, shown instead of the image.
+	source  image.Image
+	kind    string
+	img     widget.Image
+	loading bool
+}
+
+func (c *cvdControls) active() bool {
+	return c.mode.Value != ""
+}
+
+// Starts simulating the current image when the mode or image changed.
+func (s *State) updateCVD(w *app.Window) {
+	c := &s.cvd
+	if !c.active() || s.curImg == nil || c.loading {
+		return
+	}
+	if c.source == s.curImg && c.kind == c.mode.Value {
+		return
+	}
+
+	c.loading = true
+	img, kind := s.curImg, c.mode.Value
+	go func() {
+		sim, err := imageManip.SimulateCVDImage(img, kind)
+		if err != nil {
+			log.Println(err)
+		} else {
+			c.img = widget.Image{Src: paint.NewImageOp(sim), Fit: widget.ScaleDown, Position: layout.Center}
+		}
+		c.source, c.kind = img, kind
+		c.loading = false
+		w.Invalidate()
+	}()
+}
+
+// Image widget to show: the palette transfer preview or the simulation
+// when one is ready.
+func (s *State) shownImage() *widget.Image {
+	if s.transferShown() {
+		return &s.transfer.img
+	}
+	c := &s.cvd
+	if c.active() && c.source == s.curImg && c.kind == c.mode.Value && !c.loading {
+		return &c.img
+	}
+	return &s.curImgWidget
+}
+
+// col as the palette should be drawn.
+func (s *State) shownColor(col color.NRGBA) color.NRGBA {
+	if !s.cvd.active() {
+		return col
+	}
+	sim, err := imageManip.SimulateCVD(col, s.cvd.mode.Value)
+	if err != nil {
+		return col
+	}
+	return sim
+}
+
+func (s *State) cvdSection(gtx C) layout.Widget {
+	c := &s.cvd
+	margins := layout.Inset{
+		Left:  unit.Dp(MARGIN1),
+		Right: unit.Dp(MARGIN1),
+	}
+
+	buttons := []layout.FlexChild{
+		layout.Rigid(material.RadioButton(s.th, &c.mode, "", "Normal vision").Layout),
+	}
+	for _, kind := range imageManip.CVD_TYPES {
+		buttons = append(buttons, layout.Rigid(
+			material.RadioButton(s.th, &c.mode, kind, strings.ToUpper(kind[:1])+kind[1:]).Layout,
+		))
+	}
+
+	// Pairs of swatches that look alike under the chosen deficiency.
+	var warning string
+	if c.active() && len(s.palette) > 1 {
+		conflicts, err := imageManip.CVDConflicts(
+			paletteColAndFreqs(s.palette),
+			c.mode.Value,
+			imageManip.CVD_CONFLICT_DISTANCE,
+		)
+		if err == nil && len(conflicts) > 0 {
+			pairs := make([]string, len(conflicts))
+			for i, conflict := range conflicts {
+				pairs[i] = conflict.A + " / " + conflict.B
+			}
+			warning = fmt.Sprintf("Hard to tell apart with %s: %s", c.mode.Value, strings.Join(pairs, ", "))
+		}
+	}
+
+	return func(gtx C) D {
+		return margins.Layout(gtx, func(gtx C) D {
+			rows := []layout.FlexChild{
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{Alignment: layout.Middle}.Layout(gtx, buttons...)
+				}),
+			}
+			if warning != "" {
+				label := material.Body2(s.th, warning)
+				label.Color = color.NRGBA{R: 200, A: 255}
+				rows = append(rows, layout.Rigid(label.Layout))
+			}
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+		})
+	}
+}
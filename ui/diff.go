@@ -0,0 +1,108 @@
+package ui
+
+import (
+	"fmt"
+	"goPalettes/imageManip"
+	"image"
+
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// Side by side view of a pinned palette and the current one, matched with
+// imageManip.DiffPalettes. The palette is pinned when the view is turned on
+// and again with the Pin button.
+type diffControls struct {
+	show   widget.Bool
+	pin    widget.Clickable
+	pinned []imageManip.ColAndFreq
+}
+
+func (s *State) updateDiff() {
+	d := &s.diff
+	if d.show.Changed() && d.show.Value {
+		d.pinned = paletteColAndFreqs(s.palette)
+	}
+	if d.pin.Clicked() {
+		d.pinned = paletteColAndFreqs(s.palette)
+	}
+}
+
+// One column per matched pair with the pinned color on top, then the
+// removed and the added colors.
+func (s *State) diffSection(gtx C) layout.Widget {
+	d := &s.diff
+	if !d.show.Value {
+		return func(gtx C) D { return D{} }
+	}
+	diff := imageManip.DiffPalettes(d.pinned, paletteColAndFreqs(s.palette), 0)
+	margins := layout.Inset{
+		Left:  unit.Dp(MARGIN1),
+		Right: unit.Dp(MARGIN1),
+	}
+
+	// As tall as a chip so the labels line up with the rows.
+	rowLabel := func(text string) layout.Widget {
+		return func(gtx C) D {
+			gtx.Constraints.Min.Y = gtx.Dp(unit.Dp(colorBlockSize + 6))
+			return layout.W.Layout(gtx, material.Body1(s.th, text).Layout)
+		}
+	}
+	column := func(before, after, caption string) layout.FlexChild {
+		half := func(hex string) layout.Widget {
+			return func(gtx C) D {
+				return layout.UniformInset(unit.Dp(3)).Layout(gtx, func(gtx C) D {
+					size := gtx.Dp(unit.Dp(colorBlockSize))
+					if hex != "" {
+						defer clip.Rect{Max: image.Point{size, size}}.Push(gtx.Ops).Pop()
+						paint.ColorOp{Color: s.shownColor(imageManip.HexToNRGBA(hex))}.Add(gtx.Ops)
+						paint.PaintOp{}.Add(gtx.Ops)
+					}
+					return D{Size: image.Point{size, size}}
+				})
+			}
+		}
+		return layout.Rigid(func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(half(before)),
+				layout.Rigid(half(after)),
+				layout.Rigid(material.Caption(s.th, caption).Layout),
+			)
+		})
+	}
+
+	children := []layout.FlexChild{
+		layout.Rigid(func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(rowLabel("Pinned")),
+				layout.Rigid(rowLabel("Current")),
+			)
+		}),
+		layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+	}
+	for _, shift := range diff.Matched {
+		children = append(children, column(shift.From, shift.To, fmt.Sprintf("ΔE %.3f", shift.DeltaE)))
+	}
+	for _, c := range diff.Removed {
+		children = append(children, column(c.ColString, "", "removed"))
+	}
+	for _, c := range diff.Added {
+		children = append(children, column("", c.ColString, "added"))
+	}
+	children = append(children,
+		layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+		layout.Rigid(func(gtx C) D {
+			return material.Button(s.th, &d.pin, "Pin").Layout(gtx)
+		}),
+	)
+
+	return func(gtx C) D {
+		return margins.Layout(gtx, func(gtx C) D {
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx, children...)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"goPalettes/imageManip"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sqweek/dialog"
+)
+
+// Asks where to save the palette and writes it in the format matching the
+// chosen file's extension.
+func (s *State) exportPalette() {
+	d := dialog.File().Title("Export palette")
+	for _, format := range imageManip.ExportFormats() {
+		d = d.Filter(format, imageManip.ExportExtension(format))
+	}
+	path, err := d.Save()
+	if err != nil {
+		if err != dialog.ErrCancelled {
+			log.Println(err)
+		}
+		return
+	}
+
+	format := exportFormatFor(path)
+	if format == "" {
+		log.Printf("No export format saves %s files.\n", filepath.Ext(path))
+		return
+	}
+
+	palette := make([]imageManip.ColAndFreq, len(s.palette))
+	for i, c := range s.palette {
+		palette[i] = imageManip.ColAndFreq{ColString: c.hexCode}
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	f, err := os.Create(path)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer f.Close()
+	if err := imageManip.WriteExport(f, format, imageManip.NewExportData(name, palette)); err != nil {
+		log.Println(err)
+	}
+}
+
+// First format saved with path's extension.
+func exportFormatFor(path string) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	for _, format := range imageManip.ExportFormats() {
+		if imageManip.ExportExtension(format) == ext {
+			return format
+		}
+	}
+	return ""
+}
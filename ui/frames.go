@@ -0,0 +1,142 @@
+package ui
+
+import (
+	"fmt"
+	"goPalettes/imageManip"
+	"math"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// Controls shown for animated images.
+type frameControls struct {
+	frames imageManip.Frames
+	// One of the imageManip.FRAME_MODE_* constants.
+	mode     widget.Enum
+	frame    widget.Float
+	timeline []timelineRow
+	list     widget.List
+}
+
+type timelineRow struct {
+	label  string
+	blocks []colorBlock
+}
+
+func (f *frameControls) setFrames(frames imageManip.Frames) {
+	f.frames = frames
+	f.frame.Value = 0
+	f.timeline = nil
+}
+
+func (f *frameControls) setTimeline(framePalettes []imageManip.FramePalette) {
+	if len(framePalettes) < 2 {
+		f.timeline = nil
+		return
+	}
+	rows := make([]timelineRow, len(framePalettes))
+	for i, fp := range framePalettes {
+		blocks := make([]colorBlock, len(fp.Palette))
+		for j, c := range fp.Palette {
+			blocks[j] = createColorBlock(c.ColString)
+		}
+		rows[i] = timelineRow{
+			label:  fmt.Sprintf("%d (%.2fs)", fp.Frame, float64(fp.Delay)/100),
+			blocks: blocks,
+		}
+	}
+	f.timeline = rows
+}
+
+func (f *frameControls) currentFrame() int {
+	i := int(math.Round(float64(f.frame.Value)))
+	if i >= len(f.frames.Images) {
+		i = len(f.frames.Images) - 1
+	}
+	return i
+}
+
+func (s *State) updateFrames() {
+	f := &s.frameCtl
+	if !f.frames.Animated() {
+		return
+	}
+	if f.frame.Changed() {
+		s.showImage(f.frames.Images[f.currentFrame()])
+	}
+	if f.mode.Changed() {
+		f.timeline = nil
+	}
+}
+
+func (s *State) framesSection(gtx C) layout.Widget {
+	f := &s.frameCtl
+	if !f.frames.Animated() {
+		return func(gtx C) D { return D{} }
+	}
+
+	margins := layout.Inset{
+		Left:  unit.Dp(MARGIN1),
+		Right: unit.Dp(MARGIN1),
+	}
+	children := []layout.FlexChild{
+		layout.Rigid(material.RadioButton(s.th, &f.mode, imageManip.FRAME_MODE_SINGLE, "One frame").Layout),
+		layout.Rigid(material.RadioButton(s.th, &f.mode, imageManip.FRAME_MODE_MERGED, "All frames").Layout),
+		layout.Rigid(material.RadioButton(s.th, &f.mode, imageManip.FRAME_MODE_TIMELINE, "Per frame").Layout),
+	}
+	if f.mode.Value == imageManip.FRAME_MODE_SINGLE {
+		label := fmt.Sprintf("Frame %d/%d", f.currentFrame()+1, len(f.frames.Images))
+		children = append(children,
+			layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+			layout.Rigid(material.Body1(s.th, label).Layout),
+			layout.Flexed(1, material.Slider(s.th, &f.frame, 0, float32(len(f.frames.Images)-1)).Layout),
+		)
+	}
+
+	return func(gtx C) D {
+		return margins.Layout(gtx, func(gtx C) D {
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx, children...)
+		})
+	}
+}
+
+// Palette of every frame, one row per frame.
+func (s *State) timelineSection(gtx C) layout.Widget {
+	f := &s.frameCtl
+	if len(f.timeline) == 0 {
+		return func(gtx C) D { return D{} }
+	}
+
+	margins := layout.Inset{
+		Left:  unit.Dp(MARGIN1),
+		Right: unit.Dp(MARGIN1),
+	}
+	f.list.Axis = layout.Vertical
+
+	return func(gtx C) D {
+		gtx.Constraints.Max.Y = gtx.Dp(150)
+		return margins.Layout(gtx, func(gtx C) D {
+			return material.List(s.th, &f.list).Layout(gtx, len(f.timeline), func(gtx C, i int) D {
+				row := &f.timeline[i]
+				children := []layout.FlexChild{
+					layout.Rigid(func(gtx C) D {
+						gtx.Constraints.Min.X = gtx.Dp(90)
+						return material.Body2(s.th, row.label).Layout(gtx)
+					}),
+				}
+				for j := range row.blocks {
+					block := &row.blocks[j]
+					children = append(children, layout.Rigid(func(gtx C) D {
+						return layout.UniformInset(unit.Dp(2)).Layout(gtx, func(gtx C) D {
+							return block.layout(gtx, 0, false, s.shownColor(block.col))
+						})
+					}))
+				}
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx, children...)
+			})
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package ui
+
+import (
+	"fmt"
+	"goPalettes/imageManip"
+	"image"
+	"log"
+	"runtime"
+
+	"gioui.org/app"
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// Image colors harmony suggestions can snap to. More than the palette so
+// there is something to snap to in most directions.
+const HARMONY_CANDIDATES = 24
+
+// Harmony suggestions for the selected swatch. Clicking a suggestion
+// inserts it next to the swatch.
+type harmonyControls struct {
+	space     widget.Enum
+	buttonGen widget.Clickable
+	// Index of the swatch the harmonies were made from, -1 when hidden.
+	seed   int
+	sets   []imageManip.Harmony
+	clicks [][]widget.Clickable
+
+	// Image colors to snap to, extracted once per image.
+	candidates    []imageManip.ColAndFreq
+	candidatesFor image.Image
+	loading       bool
+}
+
+func (h *harmonyControls) hide() {
+	h.seed = -1
+	h.sets = nil
+}
+
+func (s *State) generateHarmonies() {
+	h := &s.editor.harmony
+	candidates := append(paletteColAndFreqs(s.palette), h.candidates...)
+	sets, err := imageManip.SuggestHarmonies(
+		s.palette[s.editor.selected].col,
+		candidates,
+		h.space.Value,
+		imageManip.HARMONY_SNAP_DISTANCE,
+	)
+	if err != nil {
+		s.setLoadErr(err)
+		return
+	}
+	h.seed = s.editor.selected
+	h.sets = sets
+	h.clicks = make([][]widget.Clickable, len(sets))
+	for i, set := range sets {
+		h.clicks[i] = make([]widget.Clickable, len(set.Colors))
+	}
+}
+
+// Extracts the colors to snap to in the background, then shows the
+// harmonies.
+func (s *State) loadHarmonyCandidates(w *app.Window) {
+	h := &s.editor.harmony
+	if s.curImg == nil || h.candidatesFor == s.curImg {
+		s.generateHarmonies()
+		return
+	}
+	h.loading = true
+	img := s.curImg
+	go func() {
+		opts := imageManip.Options{
+			Count:      HARMONY_CANDIDATES,
+			Tolerance:  5,
+			Goroutines: runtime.NumCPU(),
+		}
+		candidates, _, err := s.cache.Extract(imageManip.DEFAULT_EXTRACTOR, img, opts)
+		if err != nil {
+			log.Println(err)
+		}
+		h.candidates = candidates
+		h.candidatesFor = img
+		h.loading = false
+		if s.editor.selected >= 0 && s.editor.selected < len(s.palette) {
+			s.generateHarmonies()
+		}
+		w.Invalidate()
+	}()
+}
+
+func paletteColAndFreqs(p []colorBlock) []imageManip.ColAndFreq {
+	ret := make([]imageManip.ColAndFreq, len(p))
+	for i, block := range p {
+		ret[i] = imageManip.ColAndFreq{ColString: block.hexCode}
+	}
+	return ret
+}
+
+func (s *State) updateHarmony(w *app.Window) {
+	h := &s.editor.harmony
+	e := &s.editor
+	if h.seed >= 0 && h.seed != e.selected {
+		h.hide()
+	}
+	if e.selected < 0 || e.selected >= len(s.palette) || h.loading {
+		return
+	}
+
+	if h.buttonGen.Clicked() {
+		s.loadHarmonyCandidates(w)
+	} else if h.space.Changed() && h.seed >= 0 {
+		s.generateHarmonies()
+	}
+	for i := range h.clicks {
+		for j := range h.clicks[i] {
+			if h.clicks[i][j].Clicked() {
+				s.insertColors(h.seed+1, []string{h.sets[i].Colors[j].Hex})
+				return
+			}
+		}
+	}
+}
+
+func (s *State) harmonySection(gtx C) layout.Widget {
+	h := &s.editor.harmony
+	return func(gtx C) D {
+		label := "Harmonies"
+		if h.loading {
+			label = "Loading..."
+			gtx = gtx.Disabled()
+		}
+		row := func(gtx C) D {
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(material.RadioButton(s.th, &h.space, imageManip.HARMONY_SPACE_OKLCH, "OKLCH").Layout),
+				layout.Rigid(material.RadioButton(s.th, &h.space, imageManip.HARMONY_SPACE_HSL, "HSL").Layout),
+				layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+				layout.Rigid(material.Button(s.th, &h.buttonGen, label).Layout),
+			)
+		}
+		if h.seed < 0 {
+			return row(gtx)
+		}
+
+		rows := []layout.FlexChild{layout.Rigid(row)}
+		for i := range h.sets {
+			i := i
+			set := h.sets[i]
+			chips := []layout.FlexChild{
+				layout.Rigid(func(gtx C) D {
+					gtx.Constraints.Min.X = gtx.Dp(unit.Dp(140))
+					return material.Body2(s.th, set.Kind).Layout(gtx)
+				}),
+			}
+			for j := range set.Colors {
+				j := j
+				c := set.Colors[j]
+				// Image colors are marked so it's clear which ones are real.
+				caption := fmt.Sprintf("%+.0f°", c.Rotation)
+				if c.Snapped {
+					caption += " img"
+				}
+				chips = append(chips, layout.Rigid(func(gtx C) D {
+					return s.chip(gtx, &h.clicks[i][j], c.Color, caption)
+				}))
+			}
+			rows = append(rows, layout.Rigid(func(gtx C) D {
+				return layout.Flex{Alignment: layout.Middle}.Layout(gtx, chips...)
+			}))
+		}
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx, rows...)
+	}
+}
@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"goPalettes/imageManip"
+	"image"
+	"io"
+	"log"
+	"strings"
+
+	"gioui.org/io/clipboard"
+	"gioui.org/io/key"
+	"gioui.org/io/transfer"
+	"gioui.org/op/clip"
+)
+
+// MIME types accepted when something is dropped onto the image section.
+var dropMimeTypes = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/bmp",
+	"image/tiff",
+	"image/webp",
+	"text/uri-list",
+	"text/plain",
+}
+
+// Receives dropped images and pasted image paths.
+type imageDropTarget struct {
+	// Set when a paste shortcut was seen and the clipboard was requested.
+	readClipboard bool
+}
+
+// Handles drop and clipboard events registered in the previous frame.
+func (s *State) updateImageDrop(gtx C) {
+	d := &s.imageDrop
+
+	for _, e := range gtx.Events(d) {
+		switch e := e.(type) {
+		case key.Event:
+			if e.State == key.Press && e.Name == "V" && e.Modifiers.Contain(key.ModShortcut) {
+				d.readClipboard = true
+			}
+		case clipboard.Event:
+			s.setLoadErr(s.loadPasted(e.Text))
+		case transfer.DataEvent:
+			s.setLoadErr(s.loadDropped(e))
+		}
+	}
+}
+
+// Gio only reads text from the clipboard, so a path or URI can be pasted
+// but copied image data can't.
+func (s *State) loadPasted(text string) error {
+	if strings.TrimSpace(text) == "" {
+		return errors.New("The clipboard holds no text. Paste the path of an image, or drop the image itself.")
+	}
+	src, err := imageManip.ImageSourceFromText(text)
+	if err == nil {
+		err = s.SetCurImage(src)
+	}
+	if err != nil {
+		return fmt.Errorf("Pasted text is not the path of an image: %w", err)
+	}
+	return nil
+}
+
+func (s *State) loadDropped(e transfer.DataEvent) error {
+	rc := e.Open()
+	defer rc.Close()
+
+	if !strings.HasPrefix(e.Type, "text/") {
+		return s.SetCurImage(io.Reader(rc))
+	}
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return err
+	}
+	src, err := imageManip.ImageSourceFromText(string(data))
+	if err != nil {
+		return err
+	}
+	return s.SetCurImage(src)
+}
+
+func (s *State) setLoadErr(err error) {
+	if err != nil {
+		log.Println(err)
+		s.loadErr = err
+	}
+}
+
+// Registers the drop target and the paste path shortcut over an area of
+// size.
+func (d *imageDropTarget) layout(gtx C, size image.Point) {
+	defer clip.Rect{Max: size}.Push(gtx.Ops).Pop()
+
+	for _, mime := range dropMimeTypes {
+		transfer.TargetOp{Tag: d, Type: mime}.Add(gtx.Ops)
+	}
+	key.InputOp{Tag: d, Keys: key.Set("Short-V")}.Add(gtx.Ops)
+
+	if d.readClipboard {
+		clipboard.ReadOp{Tag: d}.Add(gtx.Ops)
+		d.readClipboard = false
+	}
+}
@@ -0,0 +1,297 @@
+package ui
+
+import (
+	"fmt"
+	"goPalettes/imageManip"
+	"image/color"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// Colors closer than this (RGB distance) to a locked swatch are not
+// offered again when the unlocked slots are re-rolled.
+const LOCKED_EXCLUSION_DISTANCE = 40
+
+// Snapshots of the palette taken before every edit.
+type paletteHistory struct {
+	undo [][]colorBlock
+	redo [][]colorBlock
+}
+
+func copyPalette(p []colorBlock) []colorBlock {
+	ret := make([]colorBlock, len(p))
+	copy(ret, p)
+	return ret
+}
+
+func (h *paletteHistory) push(p []colorBlock) {
+	h.undo = append(h.undo, copyPalette(p))
+	h.redo = nil
+}
+
+func (h *paletteHistory) canUndo() bool {
+	return len(h.undo) > 0
+}
+
+func (h *paletteHistory) canRedo() bool {
+	return len(h.redo) > 0
+}
+
+// Returns the previous palette and saves cur for redo.
+func (h *paletteHistory) stepBack(cur []colorBlock) []colorBlock {
+	prev := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, copyPalette(cur))
+	return prev
+}
+
+// Returns the next palette and saves cur for undo.
+func (h *paletteHistory) stepForward(cur []colorBlock) []colorBlock {
+	next := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, copyPalette(cur))
+	return next
+}
+
+// State of the swatch editor: the selected swatch and the picker widgets.
+type paletteEditor struct {
+	history   paletteHistory
+	selected  int
+	adjusting bool
+
+	// "hsl" or "oklch"
+	pickerMode widget.Enum
+	sliders    [3]widget.Float
+	lockBox    widget.Bool
+
+	buttonDelete widget.Clickable
+	buttonUndo   widget.Clickable
+	buttonRedo   widget.Clickable
+
+	drag    swatchDrag
+	tones   tonalControls
+	harmony harmonyControls
+}
+
+// Tracks a swatch being dragged to a new position.
+type swatchDrag struct {
+	active  bool
+	index   int
+	startX  float32
+	offsetX float32
+	drawnX  float32
+}
+
+func (s *State) selectSwatch(i int) {
+	s.editor.selected = i
+	s.syncEditor()
+}
+
+// Copies the selected swatch into the picker widgets.
+func (s *State) syncEditor() {
+	e := &s.editor
+	if e.selected < 0 || e.selected >= len(s.palette) {
+		e.selected = -1
+		return
+	}
+	block := s.palette[e.selected]
+	e.lockBox.Value = block.locked
+
+	switch e.pickerMode.Value {
+	case "oklch":
+		lch := imageManip.NRGBAToOKLCH(block.col)
+		e.sliders[0].Value = float32(lch.L)
+		e.sliders[1].Value = float32(lch.C)
+		e.sliders[2].Value = float32(lch.H)
+	default:
+		h, sat, l := imageManip.NRGBAToHSL(block.col)
+		e.sliders[0].Value = float32(h)
+		e.sliders[1].Value = float32(sat)
+		e.sliders[2].Value = float32(l)
+	}
+}
+
+// Slider ranges for the current picker mode.
+func (e *paletteEditor) sliderRanges() (labels [3]string, mins, maxs [3]float32) {
+	if e.pickerMode.Value == "oklch" {
+		return [3]string{"L", "C", "H"}, [3]float32{0, 0, 0}, [3]float32{1, 0.37, 360}
+	}
+	return [3]string{"H", "S", "L"}, [3]float32{0, 0, 0}, [3]float32{360, 1, 1}
+}
+
+func (s *State) pickerColor() color.NRGBA {
+	e := &s.editor
+	v0 := float64(e.sliders[0].Value)
+	v1 := float64(e.sliders[1].Value)
+	v2 := float64(e.sliders[2].Value)
+	if e.pickerMode.Value == "oklch" {
+		return imageManip.OKLCHToNRGBA(imageManip.OKLCH{L: v0, C: v1, H: v2})
+	}
+	return imageManip.HSLToNRGBA(v0, v1, v2)
+}
+
+// Handles the events of the editor widgets before they are laid out.
+func (s *State) updateEditor() {
+	e := &s.editor
+
+	if e.buttonUndo.Clicked() && e.history.canUndo() {
+		s.palette = e.history.stepBack(s.palette)
+		e.tones.hide()
+		e.harmony.hide()
+		s.syncEditor()
+	}
+	if e.buttonRedo.Clicked() && e.history.canRedo() {
+		s.palette = e.history.stepForward(s.palette)
+		e.tones.hide()
+		e.harmony.hide()
+		s.syncEditor()
+	}
+
+	if e.pickerMode.Changed() {
+		s.syncEditor()
+	}
+
+	s.updateTones()
+	if e.selected < 0 || e.selected >= len(s.palette) {
+		return
+	}
+
+	if e.buttonDelete.Clicked() {
+		e.history.push(s.palette)
+		s.palette = append(s.palette[:e.selected], s.palette[e.selected+1:]...)
+		e.selected = -1
+		return
+	}
+
+	if e.lockBox.Changed() {
+		e.history.push(s.palette)
+		s.palette[e.selected].locked = e.lockBox.Value
+	}
+
+	changed := false
+	dragging := false
+	for i := range e.sliders {
+		if e.sliders[i].Changed() {
+			changed = true
+		}
+		if e.sliders[i].Dragging() {
+			dragging = true
+		}
+	}
+	if changed {
+		// One undo step per slider drag, not per frame.
+		if !e.adjusting {
+			e.history.push(s.palette)
+			e.adjusting = true
+		}
+		s.palette[e.selected].setColor(s.pickerColor())
+	}
+	if !dragging {
+		e.adjusting = false
+	}
+}
+
+// Moves the swatch at index from to index to.
+func (s *State) moveSwatch(from, to int) {
+	if to < 0 {
+		to = 0
+	}
+	if to > len(s.palette)-1 {
+		to = len(s.palette) - 1
+	}
+	if from == to {
+		return
+	}
+	s.editor.history.push(s.palette)
+
+	block := s.palette[from]
+	s.palette = append(s.palette[:from], s.palette[from+1:]...)
+	s.palette = append(s.palette[:to], append([]colorBlock{block}, s.palette[to:]...)...)
+
+	if s.editor.selected == from {
+		s.editor.selected = to
+	}
+	s.syncEditor()
+}
+
+// Builds the palette after a re-roll: locked swatches stay where they are,
+// the unlocked slots are filled in order with the newly extracted colors.
+func mergeRerolledPalette(old []colorBlock, colors []imageManip.ColAndFreq) []colorBlock {
+	ret := make([]colorBlock, 0, len(old))
+	next := 0
+	for _, block := range old {
+		if block.locked {
+			ret = append(ret, block)
+		} else if next < len(colors) {
+			ret = append(ret, createColorBlock(colors[next].ColString))
+			next++
+		}
+	}
+	for ; next < len(colors); next++ {
+		ret = append(ret, createColorBlock(colors[next].ColString))
+	}
+	return ret
+}
+
+func lockedHexCodes(p []colorBlock) []string {
+	var ret []string
+	for _, block := range p {
+		if block.locked {
+			ret = append(ret, block.hexCode)
+		}
+	}
+	return ret
+}
+
+func (s *State) editorSection(gtx C) layout.Widget {
+	e := &s.editor
+	margins := layout.Inset{
+		Left:  unit.Dp(MARGIN1),
+		Right: unit.Dp(MARGIN1),
+	}
+
+	if e.selected < 0 || e.selected >= len(s.palette) {
+		return func(gtx C) D { return D{} }
+	}
+
+	labels, mins, maxs := e.sliderRanges()
+	var sliderRows []layout.FlexChild
+	for i := range e.sliders {
+		i := i
+		sliderRows = append(sliderRows, layout.Rigid(func(gtx C) D {
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+				layout.Rigid(material.Body1(s.th, labels[i]).Layout),
+				layout.Flexed(1, material.Slider(s.th, &e.sliders[i], mins[i], maxs[i]).Layout),
+			)
+		}))
+	}
+
+	block := s.palette[e.selected]
+	return func(gtx C) D {
+		return margins.Layout(gtx, func(gtx C) D {
+			return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{Alignment: layout.Middle}.Layout(gtx,
+						layout.Rigid(material.H6(s.th, block.hexCode).Layout),
+						layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+						layout.Rigid(material.Body1(s.th, fmt.Sprintf("%s (ΔE %.3f)", block.name.Label(), block.name.DeltaE)).Layout),
+						layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+						layout.Rigid(material.RadioButton(s.th, &e.pickerMode, "hsl", "HSL").Layout),
+						layout.Rigid(material.RadioButton(s.th, &e.pickerMode, "oklch", "OKLCH").Layout),
+						layout.Rigid(material.CheckBox(s.th, &e.lockBox, "Locked").Layout),
+						layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+						layout.Rigid(material.Button(s.th, &e.buttonDelete, "Delete").Layout),
+					)
+				}),
+				layout.Rigid(func(gtx C) D {
+					return layout.Flex{Axis: layout.Vertical}.Layout(gtx, sliderRows...)
+				}),
+				layout.Rigid(s.tonesSection(gtx)),
+				layout.Rigid(s.harmonySection(gtx)),
+			)
+		})
+	}
+}
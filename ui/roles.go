@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"fmt"
+	"goPalettes/imageManip"
+	"image"
+	"log"
+	"runtime"
+
+	"gioui.org/app"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+const roleTileWidth = 96
+
+// Vibrant / Muted swatches of the image, from their own extraction with
+// imageManip.ROLE_CANDIDATES colors. Clicking one adds it to the palette.
+type roleControls struct {
+	show widget.Bool
+
+	swatches  []imageManip.RoleSwatch
+	clicks    []widget.Clickable
+	source    image.Image
+	algorithm string
+	loading   bool
+}
+
+// Extracts the role candidates when the view is shown and the image or
+// algorithm changed.
+func (s *State) updateRoles(w *app.Window) {
+	r := &s.roles
+	for i := range r.clicks {
+		if r.clicks[i].Clicked() {
+			s.insertColors(len(s.palette), []string{r.swatches[i].Hex})
+		}
+	}
+	if !r.show.Value || s.curImg == nil || r.loading {
+		return
+	}
+	if r.source == s.curImg && r.algorithm == s.algorithm.Value {
+		return
+	}
+
+	r.loading = true
+	img, algorithm := s.curImg, s.algorithm.Value
+	go func() {
+		opts := imageManip.Options{
+			Count:      imageManip.ROLE_CANDIDATES,
+			Tolerance:  5,
+			Goroutines: runtime.NumCPU(),
+		}
+		candidates, _, err := s.cache.Extract(algorithm, img, opts)
+		if err != nil {
+			log.Println(err)
+		}
+		r.swatches = imageManip.PaletteRoles(candidates)
+		r.clicks = make([]widget.Clickable, len(r.swatches))
+		r.source, r.algorithm = img, algorithm
+		r.loading = false
+		w.Invalidate()
+	}()
+}
+
+func (s *State) rolesSection(gtx C) layout.Widget {
+	r := &s.roles
+	if !r.show.Value || s.curImg == nil {
+		return func(gtx C) D { return D{} }
+	}
+	margins := layout.Inset{
+		Left:  unit.Dp(MARGIN1),
+		Right: unit.Dp(MARGIN1),
+	}
+	if r.loading || r.source != s.curImg {
+		return func(gtx C) D {
+			return margins.Layout(gtx, material.Body1(s.th, "Finding roles...").Layout)
+		}
+	}
+
+	var tiles []layout.FlexChild
+	for i := range r.swatches {
+		i := i
+		tiles = append(tiles, layout.Rigid(func(gtx C) D {
+			return layout.UniformInset(unit.Dp(3)).Layout(gtx, func(gtx C) D {
+				return r.clicks[i].Layout(gtx, func(gtx C) D {
+					return s.roleTile(gtx, r.swatches[i])
+				})
+			})
+		}))
+	}
+	return func(gtx C) D {
+		return margins.Layout(gtx, func(gtx C) D {
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx, tiles...)
+		})
+	}
+}
+
+// The swatch with its role in the title text color and its population in
+// the body text color.
+func (s *State) roleTile(gtx C, swatch imageManip.RoleSwatch) D {
+	size := image.Point{gtx.Dp(unit.Dp(roleTileWidth)), gtx.Dp(unit.Dp(colorBlockSize))}
+	rect := clip.Rect{Max: size}.Push(gtx.Ops)
+	paint.ColorOp{Color: s.shownColor(swatch.Color)}.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	rect.Pop()
+
+	gtx.Constraints = layout.Exact(size)
+	layout.Center.Layout(gtx, func(gtx C) D {
+		title := material.Body2(s.th, swatch.Role)
+		title.Color = swatch.TitleColor
+		body := material.Caption(s.th, fmt.Sprintf("%s · %d", swatch.Hex, swatch.Population))
+		body.Color = swatch.BodyColor
+		return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+			layout.Rigid(title.Layout),
+			layout.Rigid(body.Layout),
+		)
+	})
+	return D{Size: size}
+}
@@ -0,0 +1,125 @@
+package ui
+
+import (
+	"goPalettes/imageManip"
+	"strconv"
+
+	"gioui.org/layout"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+)
+
+// Tonal scale of the selected swatch. Clicking a step inserts it into the
+// palette next to the swatch.
+type tonalControls struct {
+	scale     widget.Enum
+	buttonGen widget.Clickable
+	buttonAll widget.Clickable
+	// Index of the swatch the shades were made from, -1 when hidden.
+	seed   int
+	shades []imageManip.Shade
+	clicks []widget.Clickable
+}
+
+func (t *tonalControls) hide() {
+	t.seed = -1
+	t.shades = nil
+}
+
+func (s *State) generateTones() {
+	t := &s.editor.tones
+	shades, err := imageManip.TonalPalette(s.palette[s.editor.selected].col, t.scale.Value)
+	if err != nil {
+		s.setLoadErr(err)
+		return
+	}
+	t.seed = s.editor.selected
+	t.shades = shades
+	t.clicks = make([]widget.Clickable, len(shades))
+}
+
+// Inserts shades after the seed swatch.
+func (s *State) insertShades(shades []imageManip.Shade) {
+	hexCodes := make([]string, len(shades))
+	for i, shade := range shades {
+		hexCodes[i] = shade.Hex
+	}
+	s.insertColors(s.editor.tones.seed+1, hexCodes)
+}
+
+// Inserts new swatches at index at, as one undo step.
+func (s *State) insertColors(at int, hexCodes []string) {
+	s.editor.history.push(s.palette)
+	blocks := make([]colorBlock, len(hexCodes))
+	for i, hexCode := range hexCodes {
+		blocks[i] = createColorBlock(hexCode)
+	}
+	s.palette = append(s.palette[:at], append(blocks, s.palette[at:]...)...)
+}
+
+func (s *State) updateTones() {
+	t := &s.editor.tones
+	e := &s.editor
+	if t.seed >= 0 && t.seed != e.selected {
+		t.hide()
+	}
+	if e.selected < 0 || e.selected >= len(s.palette) {
+		return
+	}
+
+	if t.buttonGen.Clicked() || (t.scale.Changed() && t.seed >= 0) {
+		s.generateTones()
+	}
+	if t.seed < 0 {
+		return
+	}
+	if t.buttonAll.Clicked() {
+		s.insertShades(t.shades)
+		t.hide()
+		return
+	}
+	for i := range t.clicks {
+		if t.clicks[i].Clicked() {
+			s.insertShades(t.shades[i : i+1])
+			// The seed keeps its index, the scale stays open for more picks.
+			return
+		}
+	}
+}
+
+func (s *State) tonesSection(gtx C) layout.Widget {
+	t := &s.editor.tones
+	return func(gtx C) D {
+		children := []layout.FlexChild{
+			layout.Rigid(material.RadioButton(s.th, &t.scale, imageManip.TONAL_SCALE_TAILWIND, "Tailwind").Layout),
+			layout.Rigid(material.RadioButton(s.th, &t.scale, imageManip.TONAL_SCALE_MATERIAL, "Material").Layout),
+			layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+			layout.Rigid(material.Button(s.th, &t.buttonGen, "Tones").Layout),
+		}
+		if t.seed >= 0 {
+			children = append(children,
+				layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+				layout.Rigid(material.Button(s.th, &t.buttonAll, "Add all").Layout),
+			)
+		}
+		row := func(gtx C) D {
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx, children...)
+		}
+		if t.seed < 0 {
+			return row(gtx)
+		}
+
+		var chips []layout.FlexChild
+		for i := range t.shades {
+			i := i
+			chips = append(chips, layout.Rigid(func(gtx C) D {
+				return s.chip(gtx, &t.clicks[i], t.shades[i].Color, strconv.Itoa(t.shades[i].Step))
+			}))
+		}
+		return layout.Flex{Axis: layout.Vertical}.Layout(gtx,
+			layout.Rigid(row),
+			layout.Rigid(func(gtx C) D { return layout.Flex{}.Layout(gtx, chips...) }),
+		)
+	}
+}
@@ -0,0 +1,162 @@
+package ui
+
+import (
+	"goPalettes/imageManip"
+	"image"
+	"log"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gioui.org/app"
+	"gioui.org/layout"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"gioui.org/widget"
+	"gioui.org/widget/material"
+	"github.com/sqweek/dialog"
+)
+
+// Previews the image recolored with a target palette: the current palette,
+// or one loaded from a .gpl file or another image.
+type transferControls struct {
+	// "" for off, otherwise one of imageManip.TRANSFER_METHODS.
+	method       widget.Enum
+	chooseTarget widget.Clickable
+	usePalette   widget.Clickable
+
+	// nil while the current palette is the target.
+	target     []imageManip.ColAndFreq
+	targetName string
+
+	// Recoloring of source for key, shown instead of the image.
+	source  image.Image
+	key     string
+	img     widget.Image
+	loading bool
+}
+
+func (t *transferControls) active() bool {
+	return t.method.Value != ""
+}
+
+func (s *State) transferTarget() []imageManip.ColAndFreq {
+	if s.transfer.target != nil {
+		return s.transfer.target
+	}
+	return paletteColAndFreqs(s.palette)
+}
+
+// What the preview depends on besides the image.
+func (s *State) transferKey() string {
+	var hexCodes []string
+	for _, c := range s.transferTarget() {
+		hexCodes = append(hexCodes, c.ColString)
+	}
+	return s.transfer.method.Value + " " + s.algorithm.Value + " " + strings.Join(hexCodes, ",")
+}
+
+func (s *State) updateTransfer(w *app.Window) {
+	t := &s.transfer
+	if t.usePalette.Clicked() {
+		t.target, t.targetName = nil, ""
+	}
+	if t.chooseTarget.Clicked() && !t.loading {
+		extensions := append([]string{"gpl"}, imageManip.SUPPORTED_EXTENSIONS...)
+		path, err := dialog.File().Filter("palette or image", extensions...).Load()
+		if err != nil && err != dialog.ErrCancelled {
+			log.Println(err)
+		}
+		if path != "" {
+			t.loading = true
+			algorithm := s.algorithm.Value
+			go func() {
+				target, err := imageManip.LoadPalette(path, algorithm, imageManip.DefaultOptions())
+				if err != nil {
+					log.Println(err)
+				} else {
+					t.target, t.targetName = target, filepath.Base(path)
+				}
+				t.loading = false
+				w.Invalidate()
+			}()
+		}
+	}
+
+	if !t.active() || s.curImg == nil || t.loading || len(s.transferTarget()) == 0 {
+		return
+	}
+	key := s.transferKey()
+	if t.source == s.curImg && t.key == key {
+		return
+	}
+
+	t.loading = true
+	img, target, method, algorithm := s.curImg, s.transferTarget(), t.method.Value, s.algorithm.Value
+	go func() {
+		opts := imageManip.DefaultOptions()
+		opts.Goroutines = runtime.NumCPU()
+		result, err := imageManip.TransferPalette(img, target, method, algorithm, opts)
+		if err != nil {
+			log.Println(err)
+		} else {
+			t.img = widget.Image{Src: paint.NewImageOp(result), Fit: widget.ScaleDown, Position: layout.Center}
+		}
+		t.source, t.key = img, key
+		t.loading = false
+		w.Invalidate()
+	}()
+}
+
+// True when the recolored image is ready to be shown.
+func (s *State) transferShown() bool {
+	t := &s.transfer
+	return t.active() && !t.loading && t.source == s.curImg && t.key == s.transferKey()
+}
+
+func (s *State) transferSection(gtx C) layout.Widget {
+	t := &s.transfer
+	margins := layout.Inset{
+		Left:  unit.Dp(MARGIN1),
+		Right: unit.Dp(MARGIN1),
+	}
+
+	children := []layout.FlexChild{
+		layout.Rigid(material.Body1(s.th, "Transfer: ").Layout),
+		layout.Rigid(material.RadioButton(s.th, &t.method, "", "off").Layout),
+	}
+	for _, method := range imageManip.TRANSFER_METHODS {
+		children = append(children, layout.Rigid(material.RadioButton(s.th, &t.method, method, method).Layout))
+	}
+
+	targetLabel := "Target: palette"
+	if t.target != nil {
+		targetLabel = "Target: " + t.targetName
+	}
+	if t.loading {
+		targetLabel += " (working...)"
+	}
+	children = append(children,
+		layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+		layout.Rigid(material.Body1(s.th, targetLabel).Layout),
+		layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+		layout.Rigid(func(gtx C) D {
+			if t.loading {
+				gtx = gtx.Disabled()
+			}
+			return material.Button(s.th, &t.chooseTarget, "Choose target").Layout(gtx)
+		}),
+	)
+	if t.target != nil {
+		children = append(children,
+			layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+			layout.Rigid(material.Button(s.th, &t.usePalette, "Use palette").Layout),
+		)
+	}
+
+	return func(gtx C) D {
+		return margins.Layout(gtx, func(gtx C) D {
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx, children...)
+		})
+	}
+}
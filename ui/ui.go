@@ -1,12 +1,11 @@
 package ui
 
 import (
-	"fmt"
 	"goPalettes/imageManip"
 	"image"
 	"image/color"
 	"log"
-	"os"
+	"math"
 	"runtime"
 
 	"gioui.org/app"
@@ -28,84 +27,114 @@ type (
 )
 
 const (
-	MARGIN1 = 25
+	MARGIN1          = 25
+	CACHE_ENTRIES    = 64
+	CACHE_DISK_BYTES = 16 << 20
 )
 
 type State struct {
-	th               *material.Theme
-	curImg           image.Image
-	curImgWidget     widget.Image
-	palette          []colorBlock
-	loadingPalette   bool
+	th             *material.Theme
+	curImg         image.Image
+	curImgWidget   widget.Image
+	palette        []colorBlock
+	loadingPalette bool
+	// getPalette's goroutine sends its result here, updatePalette applies
+	// it on the UI goroutine.
+	paletteResults   chan paletteResult
 	buttonGetPalette widget.Clickable
 	buttonChooseFile widget.Clickable
+	buttonExport     widget.Clickable
+	showContrast     widget.Bool
+	roles            roleControls
+	diff             diffControls
+	algorithm        widget.Enum
+	// "" or one of imageManip.WEIGHTINGS.
+	weighting widget.Enum
+	cvd       cvdControls
+	transfer  transferControls
+	editor    paletteEditor
+	loadErr   error
+	imageDrop imageDropTarget
+	frameCtl  frameControls
+	cache     *imageManip.PaletteCache
 }
 
 func (s *State) Init() {
 	s.th = material.NewTheme(gofont.Collection())
-}
-
-func (s *State) SetCurImage(filePath string) error {
-	f, err := os.Open(filePath)
+	s.editor.selected = -1
+	s.editor.pickerMode.Value = "hsl"
+	s.algorithm.Value = imageManip.DEFAULT_EXTRACTOR
+	s.editor.tones.scale.Value = imageManip.TONAL_SCALE_TAILWIND
+	s.editor.tones.hide()
+	s.editor.harmony.space.Value = imageManip.HARMONY_SPACE_OKLCH
+	s.editor.harmony.hide()
+	s.frameCtl.mode.Value = imageManip.FRAME_MODE_SINGLE
+	s.paletteResults = make(chan paletteResult, 1)
+
+	// Palettes are cached on disk so reopening an image is instant.
+	// Without a cache directory the cache is memory only.
+	cacheDir, err := imageManip.DefaultCacheDir()
+	if err == nil {
+		s.cache, err = imageManip.NewPaletteCache(CACHE_ENTRIES, cacheDir, CACHE_DISK_BYTES)
+	}
 	if err != nil {
-		return err
+		log.Println(err)
+		s.cache, _ = imageManip.NewPaletteCache(CACHE_ENTRIES, "", 0)
 	}
-	defer f.Close()
+}
 
-	img, _, err := image.Decode(f)
+// src can be a file path, raw encoded bytes or an io.Reader.
+func (s *State) SetCurImage(src interface{}) error {
+	frames, err := imageManip.LoadFrames(src)
 	if err != nil {
 		return err
 	}
 
+	s.loadErr = nil
+	s.frameCtl.setFrames(frames)
+	s.showImage(frames.Images[0])
+
+	return nil
+}
+
+func (s *State) showImage(img image.Image) {
 	s.curImg = img
 	s.curImgWidget.Src = paint.NewImageOp(img)
 	s.curImgWidget.Fit = widget.ScaleDown
 	s.curImgWidget.Position = layout.Center
-
-	return nil
 }
 
 func (s *State) Layout(w *app.Window, gtx C) {
 
 	if s.buttonChooseFile.Clicked() {
-		path, err := dialog.File().Filter("image", "png", "jpg").Load()
-		if err != nil {
-			if err != dialog.ErrCancelled {
-				log.Fatal(err)
-			}
+		path, err := dialog.File().Filter("image", imageManip.SUPPORTED_EXTENSIONS...).Load()
+		if err != nil && err != dialog.ErrCancelled {
+			log.Println(err)
 		}
 
 		if len(path) > 0 {
-			err := s.SetCurImage(path)
-			if err != nil {
-				log.Fatal(err)
-			}
+			s.setLoadErr(s.SetCurImage(path))
 		}
 	}
 
 	if s.buttonGetPalette.Clicked() {
-		go func() {
-			s.loadingPalette = true
-			numOfColors := 5
-			goRoutines := runtime.NumCPU()
-			var tolerance float64 = 10
-			colors := imageManip.ExtractPaletteConcurrent(
-				s.curImg,
-				numOfColors,
-				goRoutines,
-				tolerance,
-			)
-			fmt.Println(colors)
-			p := make([]colorBlock, len(colors))
-			for i, c := range colors {
-				p[i] = createColorBlock(c.ColString)
-			}
-			s.palette = p
-			s.loadingPalette = false
-			w.Invalidate()
-		}()
+		s.getPalette(w)
+	}
+
+	if s.buttonExport.Clicked() {
+		s.exportPalette()
 	}
 
+	s.updatePalette()
+	s.updateEditor()
+	s.updateHarmony(w)
+	s.updateCVD(w)
+	s.updateTransfer(w)
+	s.updateRoles(w)
+	s.updateDiff()
+	s.updateImageDrop(gtx)
+	s.updateFrames()
+
 	layout.Flex{
 		Axis:    layout.Vertical,
 		Spacing: layout.SpaceStart,
@@ -113,9 +142,36 @@ func (s *State) Layout(w *app.Window, gtx C) {
 		layout.Flexed(1,
 			s.imageSection(gtx),
 		),
+		layout.Rigid(
+			s.framesSection(gtx),
+		),
 		layout.Rigid(
 			s.paletteSection(gtx),
 		),
+		layout.Rigid(
+			s.rolesSection(gtx),
+		),
+		layout.Rigid(
+			s.diffSection(gtx),
+		),
+		layout.Rigid(
+			s.cvdSection(gtx),
+		),
+		layout.Rigid(
+			s.transferSection(gtx),
+		),
+		layout.Rigid(
+			s.timelineSection(gtx),
+		),
+		layout.Rigid(
+			s.editorSection(gtx),
+		),
+		layout.Rigid(
+			s.contrastSection(gtx),
+		),
+		layout.Rigid(
+			s.algorithmSection(gtx),
+		),
 		layout.Rigid(
 			s.controlPanelSection(gtx),
 		),
@@ -123,6 +179,88 @@ func (s *State) Layout(w *app.Window, gtx C) {
 
 }
 
+type paletteResult struct {
+	// Palette before the re-roll, for undo and the locked swatches.
+	old    []colorBlock
+	colors []imageManip.ColAndFreq
+	// nil unless frame palettes were extracted.
+	framePalettes []imageManip.FramePalette
+	err           error
+}
+
+func (s *State) getPalette(w *app.Window) {
+	if s.loadingPalette {
+		return
+	}
+	numOfColors := 5
+	// Locked swatches are kept, only the remaining slots are re-rolled.
+	old := copyPalette(s.palette)
+	locked := lockedHexCodes(old)
+	count := numOfColors - len(locked)
+	if count <= 0 {
+		return
+	}
+	// Everything the goroutine needs is copied here, it must not touch s.
+	img := s.curImg
+	frames := s.frameCtl.frames
+	frameMode := s.frameCtl.mode.Value
+	algorithm := s.algorithm.Value
+	weighting := s.weighting.Value
+	cache := s.cache
+	s.loadingPalette = true
+	go func() {
+		res := paletteResult{old: old}
+		// Extra colors make up for the ones dropped next to locked
+		// swatches.
+		opts := imageManip.Options{
+			Count:      numOfColors + len(locked),
+			Tolerance:  10,
+			Goroutines: runtime.NumCPU(),
+			Weighting:  weighting,
+		}
+		if frames.Animated() && frameMode != imageManip.FRAME_MODE_SINGLE {
+			res.framePalettes, res.err = imageManip.ExtractFramesPalette(frames, frameMode, 0, algorithm, opts)
+			if res.err == nil {
+				res.colors = res.framePalettes[0].Palette
+				for i := range res.framePalettes {
+					if len(res.framePalettes[i].Palette) > numOfColors {
+						res.framePalettes[i].Palette = res.framePalettes[i].Palette[:numOfColors]
+					}
+				}
+			}
+		} else {
+			res.colors, _, res.err = cache.Extract(algorithm, img, opts)
+		}
+		res.colors = imageManip.ExcludeNearColors(res.colors, locked, LOCKED_EXCLUSION_DISTANCE)
+		if len(res.colors) > count {
+			res.colors = res.colors[:count]
+		}
+		s.paletteResults <- res
+		w.Invalidate()
+	}()
+}
+
+// Applies the result of getPalette once it is ready.
+func (s *State) updatePalette() {
+	var res paletteResult
+	select {
+	case res = <-s.paletteResults:
+	default:
+		return
+	}
+	s.loadingPalette = false
+	if res.err != nil {
+		log.Println(res.err)
+		return
+	}
+	if res.framePalettes != nil {
+		s.frameCtl.setTimeline(res.framePalettes)
+	}
+	s.editor.history.push(res.old)
+	s.palette = mergeRerolledPalette(res.old, res.colors)
+	s.selectSwatch(-1)
+}
+
 func (s *State) imageSection(gtx C) layout.Widget {
 	return func(gtx C) D {
 
@@ -134,16 +272,22 @@ func (s *State) imageSection(gtx C) layout.Widget {
 		}
 
 		var innerWidget layout.Widget
-		if s.curImg == nil {
-			innerWidget = material.H6(s.th, "No image selected.").Layout
+		if s.loadErr != nil {
+			innerWidget = material.H6(s.th, "Could not load image: "+s.loadErr.Error()).Layout
+		} else if s.curImg == nil {
+			innerWidget = material.H6(s.th, "No image selected.\nDrop one here or paste its path.").Layout
 		} else {
-			innerWidget = s.curImgWidget.Layout
+			innerWidget = s.shownImage().Layout
 		}
 
 		return margins.Layout(gtx,
 			func(gtx C) D {
 				return border.Layout(gtx,
-					func(gtx C) D { return margins.Layout(gtx, innerWidget) },
+					func(gtx C) D {
+						dims := margins.Layout(gtx, innerWidget)
+						s.imageDrop.layout(gtx, dims.Size)
+						return dims
+					},
 				)
 			},
 		)
@@ -164,10 +308,18 @@ func (s *State) paletteSection(gtx C) layout.Widget {
 	} else {
 		var children []layout.FlexChild
 		for i := range s.palette {
-			block := &s.palette[i]
+			i := i
 			children = append(children,
 				layout.Rigid(func(gtx C) D {
-					return layout.UniformInset(unit.Dp(10)).Layout(gtx, block.layout)
+					return layout.UniformInset(unit.Dp(10)).Layout(gtx,
+						func(gtx C) D {
+							return layout.Flex{Axis: layout.Vertical, Alignment: layout.Middle}.Layout(gtx,
+								layout.Rigid(func(gtx C) D { return s.swatch(gtx, i) }),
+								layout.Rigid(layout.Spacer{Height: unit.Dp(8)}.Layout),
+								layout.Rigid(material.Caption(s.th, s.palette[i].name.Label()).Layout),
+							)
+						},
+					)
 				}),
 			)
 		}
@@ -193,44 +345,146 @@ func (s *State) paletteSection(gtx C) layout.Widget {
 type colorBlock struct {
 	hexCode string
 	col     color.NRGBA
+	// Black or white, whichever reads better on col.
+	textCol color.NRGBA
+	name    imageManip.ColorName
+	locked  bool
 }
 
 func createColorBlock(hexCode string) colorBlock {
-	return colorBlock{
-		hexCode: hexCode,
-		col:     imageManip.HexToNRGBA(hexCode),
-	}
+	var c colorBlock
+	c.setColor(imageManip.HexToNRGBA(hexCode))
+	return c
 }
 
-func (c *colorBlock) layout(gtx C) D {
-	const size = 30
-	yOffset := 5 // TODO: figure out how to make this dynamic based on height of label
-	//yOffset := (gtx.Constraints.Max.Y - size) / 2
-	//fmt.Printf("%v %d\n", gtx.Constraints, yOffset)
+func (c *colorBlock) setColor(col color.NRGBA) {
+	c.col = col
+	c.hexCode = imageManip.NRGBAToHex(col)
+	c.textCol = imageManip.BestTextColor(col, nil)
+	c.name, _ = imageManip.NearestColorName(col, imageManip.COLOR_NAMES_ALL)
+}
 
-	for _, e := range gtx.Events(c) {
-		if e, ok := e.(pointer.Event); ok {
-			if e.Type == pointer.Press {
-				fmt.Printf("%s was clicked.\n", c.hexCode)
+// Handles clicks and drags on the i'th swatch. A press selects the swatch,
+// dragging it sideways moves it to another slot on release.
+func (s *State) swatch(gtx C, i int) D {
+	block := &s.palette[i]
+	drag := &s.editor.drag
+	// Width of one swatch plus the inset on both sides.
+	slotWidth := float32(colorBlockSize + 2*gtx.Dp(10))
+
+	for _, e := range gtx.Events(block) {
+		e, ok := e.(pointer.Event)
+		if !ok {
+			continue
+		}
+		switch e.Type {
+		case pointer.Press:
+			s.selectSwatch(i)
+			*drag = swatchDrag{active: true, index: i, startX: e.Position.X}
+		case pointer.Drag:
+			if drag.active && drag.index == i {
+				// Positions are relative to where the swatch was drawn in the
+				// last frame, which already includes the drag offset.
+				drag.offsetX = e.Position.X + drag.drawnX - drag.startX
+			}
+		case pointer.Release, pointer.Cancel:
+			if drag.active && drag.index == i {
+				shift := int(math.Round(float64(drag.offsetX / slotWidth)))
+				*drag = swatchDrag{}
+				if e.Type == pointer.Release && shift != 0 {
+					s.moveSwatch(i, i+shift)
+					// The block under this index changed, draw the new one.
+					block = &s.palette[i]
+				}
 			}
 		}
 	}
 
-	op.Offset(image.Point{Y: yOffset}).Add(gtx.Ops)
+	offset := 0
+	if drag.active && drag.index == i {
+		offset = int(drag.offsetX)
+		drag.drawnX = float32(offset)
+	}
+	return block.layout(gtx, offset, s.editor.selected == i, s.shownColor(block.col))
+}
+
+const colorBlockSize = 30
+
+// col is the color drawn, which differs from c.col while simulating a
+// color vision deficiency.
+func (c *colorBlock) layout(gtx C, xOffset int, selected bool, col color.NRGBA) D {
+	const size = colorBlockSize
+	yOffset := 5 // TODO: figure out how to make this dynamic based on height of label
+	//yOffset := (gtx.Constraints.Max.Y - size) / 2
+	//fmt.Printf("%v %d\n", gtx.Constraints, yOffset)
+
+	defer op.Offset(image.Point{X: xOffset, Y: yOffset}).Push(gtx.Ops).Pop()
 	area := clip.Rect{
 		Max: image.Point{size, size},
 	}.Push(gtx.Ops)
-	pointer.InputOp{Tag: c, Types: pointer.Press}.Add(gtx.Ops)
+	pointer.InputOp{
+		Tag:   c,
+		Types: pointer.Press | pointer.Drag | pointer.Release | pointer.Cancel,
+		Grab:  true,
+	}.Add(gtx.Ops)
 	pointer.CursorPointer.Add(gtx.Ops)
 
-	paint.ColorOp{Color: c.col}.Add(gtx.Ops)
+	paint.ColorOp{Color: col}.Add(gtx.Ops)
 	paint.PaintOp{}.Add(gtx.Ops)
 
 	area.Pop()
 
+	// Selected swatches get an underline, locked ones a dot in the corner
+	// drawn in the swatch's text color.
+	if selected {
+		bar := clip.Rect{Min: image.Point{0, size + 3}, Max: image.Point{size, size + 6}}.Push(gtx.Ops)
+		paint.ColorOp{Color: color.NRGBA{A: 255}}.Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		bar.Pop()
+	}
+	if c.locked {
+		dot := clip.Ellipse{Min: image.Point{size - 9, 3}, Max: image.Point{size - 3, 9}}.Push(gtx.Ops)
+		paint.ColorOp{Color: c.textCol}.Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		dot.Pop()
+	}
+
 	return layout.Dimensions{Size: image.Point{X: size, Y: size}}
 }
 
+// Extractor and, for frequency, pixel weighting used by "Get palette",
+// also when it re-rolls the swatches that aren't locked.
+func (s *State) algorithmSection(gtx C) layout.Widget {
+	margins := layout.Inset{
+		Left:  unit.Dp(MARGIN1),
+		Right: unit.Dp(MARGIN1),
+	}
+	children := []layout.FlexChild{
+		layout.Rigid(material.Body1(s.th, "Algorithm: ").Layout),
+	}
+	for _, name := range imageManip.ExtractorNames() {
+		children = append(children, layout.Rigid(material.RadioButton(s.th, &s.algorithm, name, name).Layout))
+	}
+	if s.algorithm.Value == "frequency" {
+		children = append(children,
+			layout.Rigid(layout.Spacer{Width: unit.Dp(10)}.Layout),
+			layout.Rigid(material.Body1(s.th, "Weighting: ").Layout),
+			layout.Rigid(material.RadioButton(s.th, &s.weighting, "", "none").Layout),
+		)
+		for _, name := range imageManip.WEIGHTINGS {
+			children = append(children, layout.Rigid(material.RadioButton(s.th, &s.weighting, name, name).Layout))
+		}
+	}
+	return func(gtx C) D {
+		if s.loadingPalette {
+			gtx = gtx.Disabled()
+		}
+		return margins.Layout(gtx, func(gtx C) D {
+			return layout.Flex{Alignment: layout.Middle}.Layout(gtx, children...)
+		})
+	}
+}
+
 func (s *State) controlPanelSection(gtx C) layout.Widget {
 	margins := layout.UniformInset(unit.Dp(MARGIN1))
 
@@ -239,6 +493,18 @@ func (s *State) controlPanelSection(gtx C) layout.Widget {
 			Spacing: layout.SpaceEvenly,
 		}.Layout(gtx,
 			layout.Flexed(1, s.buttonWidget(gtx, "Get palette", &s.buttonGetPalette, margins, s.curImg == nil)),
+			layout.Rigid(s.buttonWidget(gtx, "Undo", &s.editor.buttonUndo, margins, !s.editor.history.canUndo())),
+			layout.Rigid(s.buttonWidget(gtx, "Redo", &s.editor.buttonRedo, margins, !s.editor.history.canRedo())),
+			layout.Rigid(func(gtx C) D {
+				return margins.Layout(gtx, material.CheckBox(s.th, &s.showContrast, "Contrast").Layout)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return margins.Layout(gtx, material.CheckBox(s.th, &s.roles.show, "Roles").Layout)
+			}),
+			layout.Rigid(func(gtx C) D {
+				return margins.Layout(gtx, material.CheckBox(s.th, &s.diff.show, "Compare").Layout)
+			}),
+			layout.Rigid(s.buttonWidget(gtx, "Export", &s.buttonExport, margins, len(s.palette) == 0)),
 			layout.Rigid(s.buttonWidget(gtx, "Choose file", &s.buttonChooseFile, margins, s.loadingPalette)),
 		)
 	}